@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyCount pairs a key with its recorded frequency
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// FrequencyCounter is a thread-safe, bounded counter of key frequencies
+// When full, every count is halved and zero counts are dropped, so recent
+// traffic outweighs old traffic and memory stays bounded
+type FrequencyCounter struct {
+	capacity int
+	mu       sync.Mutex
+	counts   map[string]uint64
+}
+
+// NewFrequencyCounter creates a counter tracking at most capacity keys
+func NewFrequencyCounter(capacity int) *FrequencyCounter {
+	return &FrequencyCounter{
+		capacity: capacity,
+		counts:   make(map[string]uint64),
+	}
+}
+
+// Add records one occurrence of key
+func (f *FrequencyCounter) Add(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, exists := f.counts[key]; !exists && len(f.counts) >= f.capacity {
+		f.decay()
+		// Still full when every key is hot; drop the newcomer
+		if len(f.counts) >= f.capacity {
+			return
+		}
+	}
+	f.counts[key]++
+}
+
+// Top returns up to n keys ordered by descending count, or nil when n <= 0
+func (f *FrequencyCounter) Top(n int) []KeyCount {
+	if n <= 0 {
+		return nil
+	}
+	f.mu.Lock()
+	top := make([]KeyCount, 0, len(f.counts))
+	for key, count := range f.counts {
+		top = append(top, KeyCount{Key: key, Count: count})
+	}
+	f.mu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Key < top[j].Key
+	})
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// decay halves every count and removes keys that reach zero
+func (f *FrequencyCounter) decay() {
+	for key, count := range f.counts {
+		if count/2 == 0 {
+			delete(f.counts, key)
+		} else {
+			f.counts[key] = count / 2
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFrequencyCounterTop(t *testing.T) {
+	f := NewFrequencyCounter(10)
+	for _, key := range []string{"b", "a", "c", "a", "b", "a"} {
+		f.Add(key)
+	}
+
+	want := []KeyCount{{Key: "a", Count: 3}, {Key: "b", Count: 2}}
+	if got := f.Top(2); !slices.Equal(got, want) {
+		t.Errorf("Top(2) = %v, want %v", got, want)
+	}
+	// Ties are ordered by key, and n past the size returns everything
+	f.Add("c")
+	want = []KeyCount{{Key: "a", Count: 3}, {Key: "b", Count: 2}, {Key: "c", Count: 2}}
+	if got := f.Top(10); !slices.Equal(got, want) {
+		t.Errorf("Top(10) = %v, want %v", got, want)
+	}
+	for _, n := range []int{0, -1} {
+		if got := f.Top(n); got != nil {
+			t.Errorf("Top(%d) = %v, want nil", n, got)
+		}
+	}
+}
+
+func TestFrequencyCounterDecay(t *testing.T) {
+	f := NewFrequencyCounter(3)
+	for range 5 {
+		f.Add("hot")
+	}
+	f.Add("warm")
+	f.Add("warm")
+	f.Add("cold")
+
+	// A new key on a full counter halves every count and drops the zeros
+	f.Add("new")
+	want := []KeyCount{{Key: "hot", Count: 2}, {Key: "new", Count: 1}, {Key: "warm", Count: 1}}
+	if got := f.Top(10); !slices.Equal(got, want) {
+		t.Errorf("after decay = %v, want %v", got, want)
+	}
+
+	// Existing keys never trigger a decay
+	f.Add("hot")
+	if got := f.Top(1); got[0].Count != 3 {
+		t.Errorf("hot = %d, want 3", got[0].Count)
+	}
+}
+
+// A newcomer is dropped when decay frees no room
+func TestFrequencyCounterFullOfHotKeys(t *testing.T) {
+	f := NewFrequencyCounter(2)
+	for range 4 {
+		f.Add("a")
+		f.Add("b")
+	}
+	f.Add("c")
+
+	want := []KeyCount{{Key: "a", Count: 2}, {Key: "b", Count: 2}}
+	if got := f.Top(10); !slices.Equal(got, want) {
+		t.Errorf("Top = %v, want %v", got, want)
+	}
+}
@@ -2,69 +2,197 @@ package cache
 
 import (
 	"container/list"
+	"path"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultShards is the number of lock shards used by NewLRUCache
+const DefaultShards = 16
+
+// minShardCapacity is the fewest entries a shard holds; smaller caches use
+// fewer shards so one hot shard can't evict while the others sit empty
+const minShardCapacity = 32
+
+// evictionScanDepth is how many entries from the LRU tail are checked for an
+// expired entry before falling back to evicting the least recently used one
+const evictionScanDepth = 8
+
 type entry struct {
 	key       string
 	value     any
 	expiresAt time.Time
 }
 
+// Stats is a point-in-time snapshot of cache counters
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Expirations uint64 `json:"expirations"`
+	Evictions   uint64 `json:"evictions"`
+	Len         int    `json:"len"`
+	Capacity    int    `json:"capacity"`
+	Shards      int    `json:"shards"`
+}
+
+// shard is an independent LRU list guarded by its own mutex
+type shard struct {
+	capacity int
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List
+}
+
 // LRUCache is a thread-safe LRU cache with TTL support
+// Keys are hashed across independently locked shards so concurrent Gets on
+// different keys do not serialize on one mutex. Recency is tracked per shard,
+// which makes eviction an approximation of global LRU.
 type LRUCache struct {
 	capacity int
 	ttl      time.Duration
-	mu       sync.RWMutex
-	items    map[string]*list.Element
-	lru      *list.List
+	shards   []*shard
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	expirations atomic.Uint64
+	evictions   atomic.Uint64
+
+	janitorOnce sync.Once
+	closeOnce   sync.Once
+	stop        chan struct{}
+	janitorDone sync.WaitGroup
 }
 
 // NewLRUCache creates a new LRU cache with specified capacity and TTL
 func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
-	return &LRUCache{
+	return NewShardedLRUCache(capacity, ttl, DefaultShards)
+}
+
+// NewShardedLRUCache creates a new LRU cache split across the given number of shards
+// The shard count is capped so every shard holds at least minShardCapacity
+// entries; a cache smaller than that uses a single shard
+func NewShardedLRUCache(capacity int, ttl time.Duration, shards int) *LRUCache {
+	if shards > capacity/minShardCapacity {
+		shards = capacity / minShardCapacity
+	}
+	if shards < 1 {
+		shards = 1
+	}
+
+	c := &LRUCache{
 		capacity: capacity,
 		ttl:      ttl,
-		items:    make(map[string]*list.Element),
-		lru:      list.New(),
+		shards:   make([]*shard, shards),
+		stop:     make(chan struct{}),
+	}
+	for i := range c.shards {
+		// Spread the remainder so shard capacities sum to the total
+		shardCapacity := capacity / shards
+		if i < capacity%shards {
+			shardCapacity++
+		}
+		c.shards[i] = &shard{
+			capacity: shardCapacity,
+			items:    make(map[string]*list.Element),
+			lru:      list.New(),
+		}
 	}
+	return c
+}
+
+// shardFor returns the shard owning key using FNV-1a
+func (c *LRUCache) shardFor(key string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return c.shards[hash%uint32(len(c.shards))]
 }
 
 // Get retrieves a value from the cache
 func (c *LRUCache) Get(key string) (any, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	value, _, ok := c.GetWithExpiry(key)
+	return value, ok
+}
 
-	elem, exists := c.items[key]
+// GetWithExpiry retrieves a value and the time it expires
+func (c *LRUCache) GetWithExpiry(key string) (any, time.Time, bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exists := s.items[key]
 	if !exists {
-		return nil, false
+		c.misses.Add(1)
+		return nil, time.Time{}, false
 	}
 
 	entry := elem.Value.(*entry)
 
 	// Check if expired
 	if time.Now().After(entry.expiresAt) {
-		c.removeElement(elem)
-		return nil, false
+		s.removeElement(elem)
+		c.expirations.Add(1)
+		c.misses.Add(1)
+		return nil, time.Time{}, false
 	}
 
 	// Move to front (most recently used)
-	c.lru.MoveToFront(elem)
-	return entry.value, true
+	s.lru.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry.value, entry.expiresAt, true
+}
+
+// Peek retrieves a value and its expiry without updating recency or counters
+func (c *LRUCache) Peek(key string) (any, time.Time, bool) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exists := s.items[key]
+	if !exists {
+		return nil, time.Time{}, false
+	}
+
+	entry := elem.Value.(*entry)
+	if time.Now().After(entry.expiresAt) {
+		return nil, time.Time{}, false
+	}
+	return entry.value, entry.expiresAt, true
 }
 
-// Set adds or updates a value in the cache
+// Set adds or updates a value in the cache using the default TTL
 func (c *LRUCache) Set(key string, value any) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL adds or updates a value with a per-entry TTL override
+// A non-positive TTL is not admitted and removes any existing entry for key
+func (c *LRUCache) SetWithTTL(key string, value any, ttl time.Duration) {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl <= 0 {
+		if elem, exists := s.items[key]; exists {
+			s.removeElement(elem)
+		}
+		return
+	}
 
 	// If key exists, update it
-	if elem, exists := c.items[key]; exists {
-		c.lru.MoveToFront(elem)
+	if elem, exists := s.items[key]; exists {
+		s.lru.MoveToFront(elem)
 		entry := elem.Value.(*entry)
 		entry.value = value
-		entry.expiresAt = time.Now().Add(c.ttl)
+		entry.expiresAt = time.Now().Add(ttl)
 		return
 	}
 
@@ -72,43 +200,192 @@ func (c *LRUCache) Set(key string, value any) {
 	newEntry := &entry{
 		key:       key,
 		value:     value,
-		expiresAt: time.Now().Add(c.ttl),
+		expiresAt: time.Now().Add(ttl),
+	}
+	elem := s.lru.PushFront(newEntry)
+	s.items[key] = elem
+
+	// Make room if over capacity, reclaiming an expired entry before
+	// evicting a live one
+	if s.lru.Len() > s.capacity {
+		if s.removeExpiredNearTail(time.Now()) {
+			c.expirations.Add(1)
+		} else if s.removeOldest() {
+			c.evictions.Add(1)
+		}
+	}
+}
+
+// Delete removes a key from the cache, reporting whether it was present
+func (c *LRUCache) Delete(key string) bool {
+	s := c.shardFor(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exists := s.items[key]
+	if !exists {
+		return false
 	}
-	elem := c.lru.PushFront(newEntry)
-	c.items[key] = elem
+	s.removeElement(elem)
+	return true
+}
+
+// DeleteMatching removes every key matching a glob pattern (see path.Match)
+// and returns the number of entries removed
+func (c *LRUCache) DeleteMatching(pattern string) (int, error) {
+	// Validate the pattern up front so a bad pattern never partially purges
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, elem := range s.items {
+			if ok, _ := path.Match(pattern, key); ok {
+				s.removeElement(elem)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed, nil
+}
 
-	// Evict if over capacity
-	if c.lru.Len() > c.capacity {
-		c.removeOldest()
+// Keys returns the live keys starting with prefix, sorted
+func (c *LRUCache) Keys(prefix string) []string {
+	now := time.Now()
+	keys := make([]string, 0)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for key, elem := range s.items {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if now.After(elem.Value.(*entry).expiresAt) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		s.mu.Unlock()
 	}
+	sort.Strings(keys)
+	return keys
 }
 
-// removeOldest removes the least recently used item
-func (c *LRUCache) removeOldest() {
-	elem := c.lru.Back()
-	if elem != nil {
-		c.removeElement(elem)
+// DeleteExpired removes every expired entry and returns the number removed
+func (c *LRUCache) DeleteExpired() int {
+	removed := 0
+	for _, s := range c.shards {
+		now := time.Now()
+		s.mu.Lock()
+		for elem := s.lru.Back(); elem != nil; {
+			prev := elem.Prev()
+			if now.After(elem.Value.(*entry).expiresAt) {
+				s.removeElement(elem)
+				removed++
+			}
+			elem = prev
+		}
+		s.mu.Unlock()
 	}
+	c.expirations.Add(uint64(removed))
+	return removed
+}
+
+// StartJanitor starts a background goroutine that sweeps expired entries
+// every interval. Calling it more than once has no effect; stop it with Close.
+func (c *LRUCache) StartJanitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	c.janitorOnce.Do(func() {
+		c.janitorDone.Add(1)
+		go func() {
+			defer c.janitorDone.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					c.DeleteExpired()
+				case <-c.stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Close stops the janitor goroutine, if running, and waits for it to exit
+func (c *LRUCache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	c.janitorDone.Wait()
+}
+
+// removeExpiredNearTail removes the first expired entry found within
+// evictionScanDepth entries of the LRU tail
+func (s *shard) removeExpiredNearTail(now time.Time) bool {
+	elem := s.lru.Back()
+	for i := 0; elem != nil && i < evictionScanDepth; i++ {
+		if now.After(elem.Value.(*entry).expiresAt) {
+			s.removeElement(elem)
+			return true
+		}
+		elem = elem.Prev()
+	}
+	return false
+}
+
+// removeOldest removes the least recently used item in the shard
+func (s *shard) removeOldest() bool {
+	elem := s.lru.Back()
+	if elem == nil {
+		return false
+	}
+	s.removeElement(elem)
+	return true
 }
 
 // removeElement removes a specific element
-func (c *LRUCache) removeElement(elem *list.Element) {
-	c.lru.Remove(elem)
+func (s *shard) removeElement(elem *list.Element) {
+	s.lru.Remove(elem)
 	entry := elem.Value.(*entry)
-	delete(c.items, entry.key)
+	delete(s.items, entry.key)
 }
 
 // Len returns the number of items in the cache
 func (c *LRUCache) Len() int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.lru.Len()
+	total := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		total += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return total
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *LRUCache) Stats() Stats {
+	return Stats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Expirations: c.expirations.Load(),
+		Evictions:   c.evictions.Load(),
+		Len:         c.Len(),
+		Capacity:    c.capacity,
+		Shards:      len(c.shards),
+	}
 }
 
 // Clear removes all items from the cache
 func (c *LRUCache) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items = make(map[string]*list.Element)
-	c.lru = list.New()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.items = make(map[string]*list.Element)
+		s.lru = list.New()
+		s.mu.Unlock()
+	}
 }
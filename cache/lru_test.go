@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewShardedLRUCache(t *testing.T) {
+	tests := []struct {
+		capacity   int
+		shards     int
+		wantShards int
+	}{
+		{capacity: 4, shards: DefaultShards, wantShards: 1},
+		{capacity: 0, shards: DefaultShards, wantShards: 1},
+		{capacity: 100, shards: DefaultShards, wantShards: 3},
+		{capacity: 1000, shards: DefaultShards, wantShards: DefaultShards},
+		{capacity: 1000, shards: 0, wantShards: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("capacity=%d/shards=%d", tt.capacity, tt.shards), func(t *testing.T) {
+			c := NewShardedLRUCache(tt.capacity, time.Hour, tt.shards)
+			if len(c.shards) != tt.wantShards {
+				t.Errorf("shards = %d, want %d", len(c.shards), tt.wantShards)
+			}
+			total := 0
+			for _, s := range c.shards {
+				if tt.capacity >= minShardCapacity && s.capacity < minShardCapacity {
+					t.Errorf("shard capacity = %d, want at least %d", s.capacity, minShardCapacity)
+				}
+				total += s.capacity
+			}
+			if total != tt.capacity {
+				t.Errorf("shard capacities sum to %d, want %d", total, tt.capacity)
+			}
+		})
+	}
+}
+
+// A small cache keeps its full capacity however its keys hash
+func TestLRUCacheSmallCapacity(t *testing.T) {
+	c := NewLRUCache(4, time.Hour)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, key)
+	}
+	if got := c.Keys(""); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("keys = %q, want all four", got)
+	}
+}
+
+func TestLRUCacheEvictionOrder(t *testing.T) {
+	c := NewShardedLRUCache(3, time.Hour, 1)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+
+	// Reading a makes b the least recently used; Peek doesn't count
+	c.Get("a")
+	c.Peek("b")
+	c.Set("d", 4)
+	if got := c.Keys(""); !slices.Equal(got, []string{"a", "c", "d"}) {
+		t.Errorf("keys after evicting = %q, want b evicted", got)
+	}
+
+	// Updating an entry also refreshes it
+	c.Set("c", 30)
+	c.Set("e", 5)
+	if got := c.Keys(""); !slices.Equal(got, []string{"c", "d", "e"}) {
+		t.Errorf("keys after update = %q, want a evicted", got)
+	}
+	if value, _ := c.Get("c"); value != 30 {
+		t.Errorf("c = %v, want 30", value)
+	}
+	if c.Len() != 3 {
+		t.Errorf("len = %d, want capacity 3", c.Len())
+	}
+}
+
+// Keys spread over the shards and the counters are shared across them
+func TestLRUCacheStats(t *testing.T) {
+	c := NewShardedLRUCache(128, time.Hour, 2)
+	keys := benchmarkKeys(64)
+	for _, key := range keys {
+		c.Set(key, key)
+	}
+	for _, s := range c.shards {
+		if s.lru.Len() == 0 {
+			t.Errorf("a shard is empty; keys did not spread")
+		}
+	}
+	for _, key := range keys {
+		c.Get(key)
+	}
+	c.Get("missing")
+
+	got := c.Stats()
+	want := Stats{Hits: 64, Misses: 1, Len: 64, Capacity: 128, Shards: 2}
+	if got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestLRUCachePeek(t *testing.T) {
+	c := NewLRUCache(10, time.Hour)
+	before := time.Now()
+	c.Set("key", "value")
+
+	value, expiresAt, ok := c.Peek("key")
+	if !ok || value != "value" {
+		t.Fatalf("Peek = %v, %v, want value", value, ok)
+	}
+	if expiresAt.Before(before.Add(time.Hour)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expiresAt = %v, want an hour from now", expiresAt)
+	}
+	if _, _, ok := c.Peek("missing"); ok {
+		t.Error("Peek found a missing key")
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Peek counted: %+v", stats)
+	}
+}
+
+func TestLRUCacheGetWithExpiry(t *testing.T) {
+	c := NewLRUCache(10, time.Hour)
+	c.SetWithTTL("key", "value", time.Minute)
+	_, want, _ := c.Peek("key")
+
+	value, expiresAt, ok := c.GetWithExpiry("key")
+	if !ok || value != "value" || !expiresAt.Equal(want) {
+		t.Errorf("GetWithExpiry = %v, %v, %v, want value expiring at %v", value, expiresAt, ok, want)
+	}
+	if _, expiresAt, ok := c.GetWithExpiry("missing"); ok || !expiresAt.IsZero() {
+		t.Errorf("GetWithExpiry(missing) = %v, %v", expiresAt, ok)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want one hit and one miss", stats)
+	}
+}
+
+func TestLRUCacheSetWithTTL(t *testing.T) {
+	c := NewLRUCache(10, time.Hour)
+	c.SetWithTTL("short", "value", time.Millisecond)
+	c.Set("long", "value")
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.Get("short"); ok {
+		t.Error("entry outlived its TTL")
+	}
+	if _, ok := c.Get("long"); !ok {
+		t.Error("entry with the default TTL expired")
+	}
+	if stats := c.Stats(); stats.Expirations != 1 || stats.Len != 1 {
+		t.Errorf("stats = %+v, want one expiration", stats)
+	}
+}
+
+func TestLRUCacheSetWithNonPositiveTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Minute} {
+		t.Run(ttl.String(), func(t *testing.T) {
+			c := NewLRUCache(10, time.Hour)
+			c.Set("key", "value")
+			c.SetWithTTL("key", "replacement", ttl)
+			c.SetWithTTL("other", "value", ttl)
+
+			if c.Len() != 0 {
+				t.Errorf("len = %d, want the entry removed and nothing added", c.Len())
+			}
+		})
+	}
+}
+
+func TestLRUCacheDeleteExpired(t *testing.T) {
+	c := NewLRUCache(100, time.Hour)
+	for _, key := range benchmarkKeys(40) {
+		c.SetWithTTL(key, key, time.Millisecond)
+	}
+	c.Set("live", "value")
+	time.Sleep(5 * time.Millisecond)
+
+	if removed := c.DeleteExpired(); removed != 40 {
+		t.Errorf("removed = %d, want 40", removed)
+	}
+	if got := c.Keys(""); !slices.Equal(got, []string{"live"}) {
+		t.Errorf("keys = %q, want only the live entry", got)
+	}
+	if stats := c.Stats(); stats.Expirations != 40 || stats.Misses != 0 {
+		t.Errorf("stats = %+v, want 40 expirations and no misses", stats)
+	}
+}
+
+// A full shard reclaims an expired entry before evicting a live one
+func TestLRUCacheEvictsExpiredFirst(t *testing.T) {
+	c := NewShardedLRUCache(3, time.Hour, 1)
+	c.Set("oldest", 1)
+	c.SetWithTTL("expiring", 2, time.Millisecond)
+	c.Set("newest", 3)
+	time.Sleep(5 * time.Millisecond)
+
+	c.Set("added", 4)
+	if got := c.Keys(""); !slices.Equal(got, []string{"added", "newest", "oldest"}) {
+		t.Errorf("keys = %q, want the expired entry reclaimed", got)
+	}
+	if stats := c.Stats(); stats.Expirations != 1 || stats.Evictions != 0 {
+		t.Errorf("stats = %+v, want an expiration and no eviction", stats)
+	}
+}
+
+func TestLRUCacheJanitor(t *testing.T) {
+	c := NewLRUCache(10, time.Hour)
+	c.SetWithTTL("key", "value", time.Millisecond)
+	c.StartJanitor(time.Millisecond)
+	// A second start is ignored
+	c.StartJanitor(time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("janitor did not sweep the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Close()
+	// Close is idempotent, and a stopped janitor no longer sweeps
+	c.Close()
+	c.SetWithTTL("key", "value", time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if c.Len() != 1 {
+		t.Errorf("len = %d, want the entry left after Close", c.Len())
+	}
+}
+
+// benchmarkKeys matches the autocomplete key shape ("word|lang")
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("駅%d|en", i)
+	}
+	return keys
+}
+
+// benchmarkCache runs a parallel read-heavy workload against c
+// getRatio is the share of operations that are Gets (the rest are Sets)
+func benchmarkCache(b *testing.B, c *LRUCache, getRatio float64) {
+	keys := benchmarkKeys(4096)
+	for _, key := range keys {
+		c.Set(key, []byte(key))
+	}
+
+	// Simulate many more goroutines than CPUs, as under Throttle(1000)
+	b.SetParallelism(64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		for pb.Next() {
+			key := keys[rng.IntN(len(keys))]
+			if rng.Float64() < getRatio {
+				c.Get(key)
+			} else {
+				c.Set(key, []byte(key))
+			}
+		}
+	})
+}
+
+// A single shard is equivalent to the previous global-mutex implementation
+func BenchmarkLRUCacheGetParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkCache(b, NewShardedLRUCache(5000, time.Hour, shards), 1.0)
+		})
+	}
+}
+
+func BenchmarkLRUCacheMixedParallel(b *testing.B) {
+	for _, shards := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			benchmarkCache(b, NewShardedLRUCache(5000, time.Hour, shards), 0.9)
+		})
+	}
+}
@@ -1,4 +1,5 @@
-// Package docs Code generated by swaggo/swag. DO NOT EDIT
+// Code generated by swaggo/swag. DO NOT EDIT.
+
 package docs
 
 import "github.com/swaggo/swag"
@@ -24,6 +25,247 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/agent/usage": {
+            "get": {
+                "description": "Token usage and estimated cost of the agent endpoints per JST day and caller (an unauthenticated fingerprint of X-API-Key), with the configured budget; usage is kept in memory and lost on restart",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Report agent token usage and cost",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-Admin-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2024-01-15\"",
+                        "description": "Only this day (YYYY-MM-DD)",
+                        "name": "day",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentUsageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid day",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/cache/entry": {
+            "get": {
+                "description": "Returns the cached value and expiry for a key",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get cache entry",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-Admin-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache name (transit, autocomplete, agent or moderation)",
+                        "name": "cache",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache key",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CacheEntry"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - unknown cache or missing key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Key not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes a single key from the named cache",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete cache entry",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-Admin-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache name (transit, autocomplete, agent or moderation)",
+                        "name": "cache",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache key",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CachePurgeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - unknown cache or missing key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/cache/keys": {
+            "get": {
+                "description": "Lists live keys in the named cache, optionally filtered by prefix",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List cache keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-Admin-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache name (transit, autocomplete, agent or moderation)",
+                        "name": "cache",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"新宿駅|\"",
+                        "description": "Key prefix",
+                        "name": "prefix",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CacheKeysResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - unknown cache",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes every key matching a glob pattern, e.g. 新宿駅|*",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge cache by pattern",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Admin API key",
+                        "name": "X-Admin-Key",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cache name (transit, autocomplete, agent or moderation)",
+                        "name": "cache",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"新宿駅|*\"",
+                        "description": "Glob pattern",
+                        "name": "pattern",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CachePurgeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - unknown cache or invalid pattern",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/autocomplete": {
             "get": {
                 "description": "Get autocomplete suggestions for station names with optional language translation",
@@ -49,9 +291,29 @@ const docTemplate = `{
                     {
                         "type": "string",
                         "example": "\"en\"",
-                        "description": "Language for response (en for English/Romaji)",
+                        "description": "Language for response (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language",
                         "name": "lang",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"hepburn\"",
+                        "description": "Romanization style for Romaji names (hepburn, passport, passport-oh, kunrei, wapuro)",
+                        "name": "romaji_style",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en-US,en;q=0.9\"",
+                        "description": "Preferred languages with quality values",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -61,6 +323,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/model.FilteredAutocompleteResponse"
                         }
                     },
+                    "304": {
+                        "description": "Not modified - If-None-Match matched the current ETag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "400": {
                         "description": "Bad request - missing or invalid parameters",
                         "schema": {
@@ -76,6 +344,29 @@ const docTemplate = `{
                 }
             }
         },
+        "/metrics/cache": {
+            "get": {
+                "description": "Returns counters and sizes for the transit and autocomplete response caches",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get cache metrics",
+                "responses": {
+                    "200": {
+                        "description": "Counters keyed by cache name",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/cache.Stats"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/transit": {
             "get": {
                 "description": "Get transit route options between two stations with optional language translation",
@@ -106,39 +397,371 @@ const docTemplate = `{
                         "in": "query",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "example": "\"都営\"",
+                        "description": "Use the start complex's node for this operator instead of any node",
+                        "name": "start_operator",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"JR\"",
+                        "description": "Use the goal complex's node for this operator instead of any node",
+                        "name": "goal_operator",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"00004254\"",
+                        "description": "Starting node ID from autocomplete members; replaces start",
+                        "name": "start_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"00004254\"",
+                        "description": "Destination node ID from autocomplete members; replaces goal",
+                        "name": "goal_id",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "example": "\"2024-01-15T09:00:00\"",
-                        "description": "Start time in format YYYY-MM-DDTHH:MM:SS",
+                        "description": "Departure time in format YYYY-MM-DDTHH:MM:SS; required unless goal_time is set",
                         "name": "start_time",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2024-01-15T19:00:00\"",
+                        "description": "Arrive-by time in format YYYY-MM-DDTHH:MM:SS, instead of start_time",
+                        "name": "goal_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"品川\"",
+                        "description": "Up to 3 comma-separated stations to pass through",
+                        "name": "via",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"superexpress_train\"",
+                        "description": "Comma-separated transport to avoid (domestic_flight, superexpress_train, sleeper_ultraexpress, ultraexpress_train, express_train, semiexpress_train, shuttle_bus)",
+                        "name": "unuse",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"transit\"",
+                        "description": "Route ordering (time_optimized, total_time, fare, transit)",
+                        "name": "order",
+                        "in": "query"
                     },
                     {
                         "type": "string",
                         "example": "\"en\"",
-                        "description": "Language for response (en for English/Romaji)",
+                        "description": "Language for response (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language",
                         "name": "lang",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en-US,en;q=0.9\"",
+                        "description": "Preferred languages with quality values",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"bilingual\"",
+                        "description": "Set to bilingual to keep Japanese names and add name_en and name_ruby",
+                        "name": "names",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"hepburn\"",
+                        "description": "Romanization style for Romaji names (hepburn, passport, passport-oh, kunrei, wapuro)",
+                        "name": "romaji_style",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "Successful response with transit routes",
                         "schema": {
-                            "$ref": "#/definitions/model.TransitResponse"
+                            "$ref": "#/definitions/model.TransitResponse"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified - If-None-Match matched the current ETag",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing or invalid parameters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/transit-agent": {
+            "post": {
+                "description": "Uses the configured LLM to determine the nearest start and end stations based on a location prompt. Pass the returned session_id with a follow-up prompt (\"what about 30 minutes later?\") to revise the previous itinerary",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transit-agent"
+                ],
+                "summary": "Find nearest stations using AI",
+                "parameters": [
+                    {
+                        "description": "Transit agent request with location prompt",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransitAgentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Verified start and end stations in Japanese with node IDs and confidence",
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransitAgentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing or invalid parameters, a prompt rejected by the input guard, or a refusal",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found or expired",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "422": {
+                        "description": "The place is not in Japan, is ambiguous, or has no real stations",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "429": {
+                        "description": "The daily token budget is used up",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "502": {
+                        "description": "The LLM failed or returned invalid output",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "504": {
+                        "description": "The LLM timed out",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    }
+                }
+            }
+        },
+        "/transit-agent/plan": {
+            "post": {
+                "description": "Uses the configured LLM with tools backed by autocomplete, node resolution and transit search to return full routes and a short explanation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transit-agent"
+                ],
+                "summary": "Plan a trip from a natural-language prompt",
+                "parameters": [
+                    {
+                        "description": "Trip description, e.g. I'm near Tokyo Tower and need to be in Shibuya by 7pm",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransitPlanRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en\"",
+                        "description": "Language for routes and explanation (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en-US,en;q=0.9\"",
+                        "description": "Preferred languages with quality values",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Planned routes with the stations chosen and an explanation",
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransitPlanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - missing or invalid parameters, a prompt rejected by the input guard, or a refusal",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "422": {
+                        "description": "No route could be planned from the prompt",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "429": {
+                        "description": "The daily token budget is used up",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "502": {
+                        "description": "The LLM failed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "504": {
+                        "description": "The LLM timed out",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    }
+                }
+            }
+        },
+        "/transit-agent/stream": {
+            "post": {
+                "description": "Emits understanding (model output deltas, numbered by attempt), candidates (closest real stations for a miss), stations (verified TransitAgentResponse), routes (TransitResponse), explanation (deltas) and done events; failures end the stream with an error event",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "transit-agent"
+                ],
+                "summary": "Stream a trip plan as Server-Sent Events",
+                "parameters": [
+                    {
+                        "description": "Transit agent request with location prompt",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.TransitAgentRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en\"",
+                        "description": "Language for routes and explanation (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language",
+                        "name": "lang",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"en-US,en;q=0.9\"",
+                        "description": "Preferred languages with quality values",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Bad request - missing or invalid parameters",
+                        "description": "Bad request - missing or invalid parameters, or a prompt too long or rejected by the input guard",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "404": {
+                        "description": "Session not found or expired",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "429": {
+                        "description": "The daily token budget is used up",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "502": {
+                        "description": "The LLM failed while moderating the prompt",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
+                        }
+                    },
+                    "504": {
+                        "description": "The LLM timed out while moderating the prompt",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AgentError"
                         }
                     }
                 }
@@ -146,6 +769,233 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "cache.Stats": {
+            "type": "object",
+            "properties": {
+                "capacity": {
+                    "type": "integer"
+                },
+                "evictions": {
+                    "type": "integer"
+                },
+                "expirations": {
+                    "type": "integer"
+                },
+                "hits": {
+                    "type": "integer"
+                },
+                "len": {
+                    "type": "integer"
+                },
+                "misses": {
+                    "type": "integer"
+                },
+                "shards": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.AgentBudget": {
+            "type": "object",
+            "properties": {
+                "completion_price": {
+                    "type": "number"
+                },
+                "daily_tokens": {
+                    "type": "integer"
+                },
+                "prompt_price": {
+                    "type": "number"
+                }
+            }
+        },
+        "handler.AgentError": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.AgentUsageDay": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "string"
+                },
+                "keys": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/handler.UsageTotals"
+                    }
+                },
+                "total": {
+                    "$ref": "#/definitions/handler.UsageTotals"
+                }
+            }
+        },
+        "handler.AgentUsageResponse": {
+            "type": "object",
+            "properties": {
+                "budget": {
+                    "$ref": "#/definitions/handler.AgentBudget"
+                },
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.AgentUsageDay"
+                    }
+                }
+            }
+        },
+        "handler.CacheEntry": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "object"
+                }
+            }
+        },
+        "handler.CacheKeysResponse": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "type": "string"
+                },
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.CachePurgeResponse": {
+            "type": "object",
+            "properties": {
+                "cache": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.TransitAgentRequest": {
+            "type": "object",
+            "properties": {
+                "prompt": {
+                    "type": "string"
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.TransitAgentResponse": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "type": "number"
+                },
+                "end_node_id": {
+                    "type": "string"
+                },
+                "end_station": {
+                    "type": "string"
+                },
+                "goal_time": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "session_id": {
+                    "type": "string"
+                },
+                "start_node_id": {
+                    "type": "string"
+                },
+                "start_station": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                },
+                "transit_query": {
+                    "type": "string"
+                },
+                "unuse": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "via": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.TransitPlanRequest": {
+            "type": "object",
+            "properties": {
+                "prompt": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.TransitPlanResponse": {
+            "type": "object",
+            "properties": {
+                "end_station": {
+                    "type": "string"
+                },
+                "explanation": {
+                    "type": "string"
+                },
+                "goal_time": {
+                    "type": "string"
+                },
+                "routes": {
+                    "$ref": "#/definitions/model.TransitResponse"
+                },
+                "start_station": {
+                    "type": "string"
+                },
+                "start_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.UsageTotals": {
+            "type": "object",
+            "properties": {
+                "completion_tokens": {
+                    "type": "integer"
+                },
+                "completions": {
+                    "type": "integer"
+                },
+                "cost_usd": {
+                    "type": "number"
+                },
+                "prompt_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
         "model.Company": {
             "type": "object",
             "properties": {
@@ -154,6 +1004,15 @@ const docTemplate = `{
                 },
                 "name": {
                     "type": "string"
+                },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
+                "ruby": {
+                    "type": "string"
                 }
             }
         },
@@ -176,6 +1035,15 @@ const docTemplate = `{
                 },
                 "name": {
                     "type": "string"
+                },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
+                "ruby": {
+                    "type": "string"
                 }
             }
         },
@@ -280,6 +1148,12 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.StationMember"
+                    }
+                },
                 "name": {
                     "type": "string"
                 },
@@ -300,6 +1174,9 @@ const docTemplate = `{
                 "direction": {
                     "type": "string"
                 },
+                "direction_label": {
+                    "type": "string"
+                },
                 "from": {
                     "$ref": "#/definitions/model.Station"
                 },
@@ -312,6 +1189,15 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
+                "ruby": {
+                    "type": "string"
+                },
                 "to": {
                     "$ref": "#/definitions/model.Station"
                 }
@@ -335,6 +1221,12 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "move_type_labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "time": {
                     "type": "integer"
                 },
@@ -375,6 +1267,12 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
                 "node_id": {
                     "type": "string"
                 },
@@ -387,6 +1285,9 @@ const docTemplate = `{
                 "numbering": {
                     "$ref": "#/definitions/model.Numbering"
                 },
+                "ruby": {
+                    "type": "string"
+                },
                 "type": {
                     "type": "string"
                 }
@@ -412,12 +1313,24 @@ const docTemplate = `{
                 "line_name": {
                     "type": "string"
                 },
+                "line_name_en": {
+                    "type": "string"
+                },
                 "move": {
                     "type": "string"
                 },
+                "move_label": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
                 "node_id": {
                     "type": "string"
                 },
@@ -430,6 +1343,9 @@ const docTemplate = `{
                 "numbering": {
                     "$ref": "#/definitions/model.Numbering"
                 },
+                "ruby": {
+                    "type": "string"
+                },
                 "time": {
                     "type": "integer"
                 },
@@ -458,6 +1374,32 @@ const docTemplate = `{
                 },
                 "name": {
                     "type": "string"
+                },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
+                "ruby": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.StationMember": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "operator": {
+                    "type": "string"
+                },
+                "prefecture": {
+                    "type": "string"
                 }
             }
         },
@@ -541,6 +1483,9 @@ const docTemplate = `{
                 "fare_season": {
                     "type": "string"
                 },
+                "fare_season_label": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -553,6 +1498,15 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "name_en": {
+                    "type": "string"
+                },
+                "name_ruby": {
+                    "type": "string"
+                },
+                "ruby": {
+                    "type": "string"
+                },
                 "type": {
                     "type": "string"
                 }
@@ -567,6 +1521,9 @@ const docTemplate = `{
                 "currency": {
                     "type": "string"
                 },
+                "currency_label": {
+                    "type": "string"
+                },
                 "datum": {
                     "type": "string"
                 },
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// agentAudit writes one JSON line per agent request
+var (
+	auditMu    sync.Mutex
+	agentAudit = log.New(os.Stdout, "[AUDIT] ", 0)
+)
+
+// openAuditLog sends the audit log to path, appending, or to stdout when empty
+func openAuditLog(path string) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if path == "" {
+		agentAudit = log.New(os.Stdout, "[AUDIT] ", 0)
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	agentAudit = log.New(file, "", 0)
+	return nil
+}
+
+// auditRecord is one audit log line; prompt and output are redacted
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Caller   string    `json:"caller"`
+	Session  string    `json:"session,omitempty"`
+	Prompt   string    `json:"prompt"`
+	Output   string    `json:"output,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// audit logs a request to an agent endpoint with personal data and secrets
+// redacted from the prompt and output
+func audit(record auditRecord) {
+	record.Time = time.Now().In(jst)
+	record.Prompt = redact(record.Prompt)
+	record.Output = redact(record.Output)
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding audit record: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	agentAudit.Print(string(line))
+}
+
+// redactions replace personal data and secrets, in order; phone numbers go
+// before card numbers and postcodes so their digits aren't split
+var redactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\b(sk|pk|rk)-[A-Za-z0-9_-]{16,}|\b[A-Za-z0-9_-]{32,}\b`), "[secret]"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[email]"},
+	{regexp.MustCompile(`(\+81[ -]?\d{1,4}|\b0\d{1,4})[ -]\d{1,4}[ -]\d{3,4}\b|\b0[789]0\d{8}\b`), "[phone]"},
+	{regexp.MustCompile(`\b(\d[ -]?){12,18}\d\b`), "[number]"},
+	{regexp.MustCompile(`〒\s?\d{3}-?\d{4}|\b\d{3}-\d{4}\b`), "[postcode]"},
+}
+
+// redact removes personal data and secrets from s for logging
+func redact(s string) string {
+	for _, r := range redactions {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
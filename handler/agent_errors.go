@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"transit-api/locale"
+)
+
+// Errors from suggestStations that map to specific responses
+var (
+	errAgentOutput        = errors.New("failed to parse LLM response")
+	errStationsUnverified = errors.New("suggested stations could not be verified")
+	errAgentRefused       = errors.New("model refused the prompt")
+	errNotInJapan         = errors.New("prompt is not about a place in Japan")
+	errAmbiguous          = errors.New("prompt is ambiguous")
+)
+
+// agentDeclined is a failure the model reported, with its reason for the user
+type agentDeclined struct {
+	kind   error
+	reason string
+}
+
+func (e *agentDeclined) Error() string {
+	return e.kind.Error() + ": " + e.reason
+}
+
+func (e *agentDeclined) Unwrap() error {
+	return e.kind
+}
+
+// AgentError is the body of a failed transit agent request
+// Error is the kind: prompt_too_long, prompt_rejected, refusal, not_in_japan,
+// ambiguous, unverified_stations, budget_exceeded, timeout, invalid_output or
+// llm_error from the model, route_not_found from the planner, or the message
+// ID of a request that failed before reaching the model (missing_parameter,
+// invalid_parameter, session_not_found, internal_error)
+type AgentError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// agentFailure maps a suggestStations error to its status and body
+// Failures the model reported carry its reason as the message
+func agentFailure(err error) (int, AgentError) {
+	status, failure := http.StatusBadGateway, AgentError{Error: "llm_error", Message: "Failed to get response from LLM"}
+	switch {
+	case errors.Is(err, errPromptTooLong):
+		status, failure = http.StatusBadRequest, AgentError{Error: "prompt_too_long", Message: "The prompt is too long"}
+	case errors.Is(err, errPromptRejected):
+		status, failure = http.StatusBadRequest, AgentError{Error: "prompt_rejected", Message: "The prompt cannot be accepted"}
+	case errors.Is(err, errAgentRefused):
+		status, failure = http.StatusBadRequest, AgentError{Error: "refusal", Message: "The prompt was declined"}
+	case errors.Is(err, errNotInJapan):
+		status, failure = http.StatusUnprocessableEntity, AgentError{Error: "not_in_japan", Message: "The prompt is not about a place in Japan"}
+	case errors.Is(err, errAmbiguous):
+		status, failure = http.StatusUnprocessableEntity, AgentError{Error: "ambiguous", Message: "The prompt could mean more than one place"}
+	case errors.Is(err, errStationsUnverified):
+		status, failure = http.StatusUnprocessableEntity, AgentError{Error: "unverified_stations", Message: "Could not find real stations for the prompt"}
+	case errors.Is(err, errBudgetExceeded):
+		status, failure = http.StatusTooManyRequests, AgentError{Error: "budget_exceeded", Message: "The daily token budget is used up"}
+	case errors.Is(err, context.DeadlineExceeded):
+		status, failure = http.StatusGatewayTimeout, AgentError{Error: "timeout", Message: "The LLM did not respond in time"}
+	case errors.Is(err, errAgentOutput):
+		failure = AgentError{Error: "invalid_output", Message: "Failed to parse AI response"}
+	}
+
+	var declined *agentDeclined
+	if errors.As(err, &declined) && declined.reason != "" {
+		failure.Message = declined.reason
+	}
+	return status, failure
+}
+
+// localizedAgentFailure is agentFailure with the message in loc, unless the
+// model gave a reason
+func localizedAgentFailure(loc locale.Locale, err error) (int, AgentError) {
+	status, failure := agentFailure(err)
+	var declined *agentDeclined
+	if !errors.As(err, &declined) || declined.reason == "" {
+		failure.Message = locale.Message(loc, agentMessage(err))
+	}
+	return status, failure
+}
+
+// requestFailure is the AgentError for message id in loc
+func requestFailure(loc locale.Locale, id locale.MessageID) AgentError {
+	return AgentError{Error: string(id), Message: locale.Message(loc, id)}
+}
+
+// writeAgentError writes failure as the JSON body of a status response
+func writeAgentError(w http.ResponseWriter, status int, failure AgentError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(failure); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// agentMessage picks the localized message for an agent failure on the
+// endpoints that localize errors
+func agentMessage(err error) locale.MessageID {
+	switch {
+	case errors.Is(err, errPromptTooLong):
+		return locale.MsgPromptTooLong
+	case errors.Is(err, errPromptRejected):
+		return locale.MsgPromptRejected
+	case errors.Is(err, errStationsUnverified):
+		return locale.MsgStationNotFound
+	case errors.Is(err, errBudgetExceeded):
+		return locale.MsgBudgetExceeded
+	default:
+		return locale.MsgAgentFailed
+	}
+}
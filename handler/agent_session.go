@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"transit-api/cache"
+	"transit-api/llm"
+)
+
+// Sessions let follow-up prompts ("what about 30 minutes later?") revise
+// the last itinerary instead of starting over
+const (
+	agentSessionCapacity = 1000
+	agentSessionTTL      = 30 * time.Minute
+	// Older turns are dropped so follow-ups don't grow the prompt without bound
+	agentSessionMaxTurns = 5
+)
+
+// agentSessions holds *agentSession values by session ID
+var agentSessions = cache.NewLRUCache(agentSessionCapacity, agentSessionTTL)
+
+// errSessionNotFound is returned for an unknown or expired session ID
+var errSessionNotFound = errors.New("session not found or expired")
+
+// agentSession is the conversation so far and the last resolved itinerary
+// Values are replaced, never modified, so readers need no lock
+type agentSession struct {
+	ID      string
+	History []llm.Message
+	Last    *TransitAgentResponse
+}
+
+// loadSession returns the session for id, or a new one when id is empty
+func loadSession(id string) (*agentSession, error) {
+	if id == "" {
+		return &agentSession{ID: rand.Text()}, nil
+	}
+	value, ok := agentSessions.Get(id)
+	if !ok {
+		return nil, errSessionNotFound
+	}
+	return value.(*agentSession), nil
+}
+
+// record stores the session with the turn for prompt and its itinerary
+// The model sees its earlier answers as the verified plan, so a follow-up
+// starts from real stations and resolved times
+func (s *agentSession) record(prompt string, response *TransitAgentResponse) {
+	answer, _ := json.Marshal(response.plan)
+	history := append(s.History[:len(s.History):len(s.History)], llm.User(prompt), llm.Assistant(string(answer)))
+	if turns := len(history) / 2; turns > agentSessionMaxTurns {
+		history = history[2*(turns-agentSessionMaxTurns):]
+	}
+	response.SessionID = s.ID
+	agentSessions.Set(s.ID, &agentSession{ID: s.ID, History: history, Last: response})
+}
+
+// prompt is the system prompt for the session's next turn at now
+func (s *agentSession) prompt(now time.Time) string {
+	prompt := transitAgentPrompt(now)
+	if s.Last == nil {
+		return prompt
+	}
+	current, _ := json.Marshal(s.Last.plan)
+	return prompt + fmt.Sprintf(`
+
+This is a follow-up. The current itinerary is %s. Start from it and change only what the user asks for: "30 minutes later" shifts its departure_time or arrival_time, "cheaper" sets prefer to cheapest, and stations the user doesn't mention stay the same.`, current)
+}
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"transit-api/llm"
+)
+
+// Usage is kept per JST day for this many days
+const agentUsageRetentionDays = 31
+
+// Default prices in USD per million tokens, those of llm.DefaultModel
+const (
+	defaultPromptPrice     = 2.50
+	defaultCompletionPrice = 10.00
+)
+
+// A completion without MaxTokens is reserved this many completion tokens
+const defaultCompletionReserve = 1000
+
+// errBudgetExceeded is returned when the service is out of tokens for the day
+var errBudgetExceeded = errors.New("daily token budget exceeded")
+
+// AgentBudget limits the tokens the agent endpoints may spend per JST day
+// A zero budget is unlimited; prices are in USD per million tokens
+// There is no per-caller budget: callers are told apart by an unauthenticated
+// header, which anyone can vary
+type AgentBudget struct {
+	DailyTokens     int64   `json:"daily_tokens"`
+	PromptPrice     float64 `json:"prompt_price"`
+	CompletionPrice float64 `json:"completion_price"`
+}
+
+// LoadAgentBudget reads AGENT_DAILY_TOKENS, LLM_PROMPT_PRICE and
+// LLM_COMPLETION_PRICE
+func LoadAgentBudget() (AgentBudget, error) {
+	budget := AgentBudget{PromptPrice: defaultPromptPrice, CompletionPrice: defaultCompletionPrice}
+	if value := os.Getenv("AGENT_DAILY_TOKENS"); value != "" {
+		tokens, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || tokens < 0 {
+			return budget, fmt.Errorf("invalid AGENT_DAILY_TOKENS %q", value)
+		}
+		budget.DailyTokens = tokens
+	}
+	for name, target := range map[string]*float64{
+		"LLM_PROMPT_PRICE":     &budget.PromptPrice,
+		"LLM_COMPLETION_PRICE": &budget.CompletionPrice,
+	} {
+		if value := os.Getenv(name); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				return budget, fmt.Errorf("invalid %s %q", name, value)
+			}
+			*target = price
+		}
+	}
+	return budget, nil
+}
+
+// UseAgentBudget sets the budgets and prices applied to the agent endpoints
+func UseAgentBudget(budget AgentBudget) {
+	agentUsage.mu.Lock()
+	defer agentUsage.mu.Unlock()
+	agentUsage.budget = budget
+}
+
+// UsageTotals is the LLM usage of one caller, or of everyone, on one day
+type UsageTotals struct {
+	Completions      int64   `json:"completions"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// tokens is the total counted against budgets
+func (t UsageTotals) tokens() int64 {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// usageLedger aggregates usage per JST day and caller key
+// It is held in memory only, so a restart forgets the day's usage and
+// resets the budget
+type usageLedger struct {
+	mu     sync.Mutex
+	budget AgentBudget
+	// days maps "2006-01-02" to caller key to totals
+	days map[string]map[string]*UsageTotals
+	// reserved maps "2006-01-02" to the tokens held by completions in flight
+	reserved map[string]int64
+}
+
+// agentUsage records every completion made by the agent endpoints
+var agentUsage = &usageLedger{
+	budget:   AgentBudget{PromptPrice: defaultPromptPrice, CompletionPrice: defaultCompletionPrice},
+	days:     map[string]map[string]*UsageTotals{},
+	reserved: map[string]int64{},
+}
+
+// usageDay is the ledger day for t
+func usageDay(t time.Time) string {
+	return t.In(jst).Format(time.DateOnly)
+}
+
+// record adds one completion's usage for key to day
+// The caller holds l.mu.
+func (l *usageLedger) record(day, key string, usage llm.Usage, now time.Time) {
+	keys, ok := l.days[day]
+	if !ok {
+		keys = map[string]*UsageTotals{}
+		l.days[day] = keys
+		// Drop days past retention whenever a new one starts
+		oldest := usageDay(now.AddDate(0, 0, -agentUsageRetentionDays))
+		for old := range l.days {
+			if old < oldest {
+				delete(l.days, old)
+			}
+		}
+	}
+	totals, ok := keys[key]
+	if !ok {
+		totals = &UsageTotals{}
+		keys[key] = totals
+	}
+	totals.Completions++
+	totals.PromptTokens += usage.PromptTokens
+	totals.CompletionTokens += usage.CompletionTokens
+	totals.CostUSD += float64(usage.PromptTokens)*l.budget.PromptPrice/1e6 +
+		float64(usage.CompletionTokens)*l.budget.CompletionPrice/1e6
+}
+
+// reserve holds tokens against the day's budget for a completion about to
+// be made, and returns the day to settle them on
+// It reports errBudgetExceeded when the tokens used and held by completions in
+// flight leave too few; checking and holding under one lock keeps concurrent
+// requests from all passing a check the budget can only cover once.
+func (l *usageLedger) reserve(tokens int64, now time.Time) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := usageDay(now)
+	if budget := l.budget.DailyTokens; budget > 0 {
+		used := l.reserved[day]
+		for _, totals := range l.days[day] {
+			used += totals.tokens()
+		}
+		if used+tokens > budget {
+			return day, errBudgetExceeded
+		}
+	}
+	l.reserved[day] += tokens
+	return day, nil
+}
+
+// settle releases a reservation and records the completion's actual usage
+// for key, when there is one
+func (l *usageLedger) settle(day string, tokens int64, key string, usage *llm.Usage, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.reserved[day] -= tokens; l.reserved[day] <= 0 {
+		delete(l.reserved, day)
+	}
+	if usage != nil {
+		l.record(day, key, *usage, now)
+	}
+}
+
+// reservation estimates the tokens req may use: its messages at about four
+// characters a token, plus its completion limit
+func reservation(req llm.Request) int64 {
+	var chars int
+	for _, message := range req.Messages {
+		chars += utf8.RuneCountInString(message.Content)
+		for _, call := range message.ToolCalls {
+			chars += utf8.RuneCountInString(call.Arguments)
+		}
+	}
+	completion := req.MaxTokens
+	if completion == 0 {
+		completion = defaultCompletionReserve
+	}
+	return int64(chars/4 + completion)
+}
+
+// AgentUsageDay is the usage of one JST day
+type AgentUsageDay struct {
+	Day   string                 `json:"day"`
+	Total UsageTotals            `json:"total"`
+	Keys  map[string]UsageTotals `json:"keys"`
+}
+
+// AgentUsageResponse is the agent usage report, newest day first
+type AgentUsageResponse struct {
+	Budget AgentBudget     `json:"budget"`
+	Days   []AgentUsageDay `json:"days"`
+}
+
+// report copies the ledger, limited to day when it is set
+func (l *usageLedger) report(day string) AgentUsageResponse {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	response := AgentUsageResponse{Budget: l.budget, Days: []AgentUsageDay{}}
+	for _, d := range slices.Backward(slices.Sorted(maps.Keys(l.days))) {
+		if day != "" && d != day {
+			continue
+		}
+		usage := AgentUsageDay{Day: d, Keys: map[string]UsageTotals{}}
+		for key, totals := range l.days[d] {
+			usage.Keys[key] = *totals
+			usage.Total.Completions += totals.Completions
+			usage.Total.PromptTokens += totals.PromptTokens
+			usage.Total.CompletionTokens += totals.CompletionTokens
+			usage.Total.CostUSD += totals.CostUSD
+		}
+		response.Days = append(response.Days, usage)
+	}
+	return response
+}
+
+// callerKey identifies the caller for usage reports and the audit log by a
+// fingerprint of its X-API-Key header, so they never hold the key itself
+// The header is not authenticated, so the key only labels usage and is
+// never trusted to limit it
+func callerKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// meteredClient records the usage of every completion against key and
+// refuses completions the day's budget can't cover; cached answers never
+// reach it
+type meteredClient struct {
+	client llm.Client
+	key    string
+}
+
+// meter wraps client so its usage is recorded for key
+func meter(client llm.Client, key string) llm.Client {
+	return &meteredClient{client: client, key: key}
+}
+
+// Complete fails with errBudgetExceeded once the day's tokens are used
+func (m *meteredClient) Complete(ctx context.Context, req llm.Request) (*llm.Response, error) {
+	return m.metered(req, func() (*llm.Response, error) {
+		return m.client.Complete(ctx, req)
+	})
+}
+
+// Stream streams when the wrapped client can, so metering keeps deltas flowing
+func (m *meteredClient) Stream(ctx context.Context, req llm.Request, onDelta func(string)) (*llm.Response, error) {
+	return m.metered(req, func() (*llm.Response, error) {
+		return llm.CompleteStream(ctx, m.client, req, onDelta)
+	})
+}
+
+// metered reserves tokens for req, makes the completion and settles the
+// reservation with its usage
+func (m *meteredClient) metered(req llm.Request, complete func() (*llm.Response, error)) (*llm.Response, error) {
+	tokens := reservation(req)
+	day, err := agentUsage.reserve(tokens, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	response, err := complete()
+	var usage *llm.Usage
+	if err == nil {
+		usage = &response.Usage
+	}
+	agentUsage.settle(day, tokens, m.key, usage, time.Now())
+	return response, err
+}
+
+// retryAfter is the Retry-After value for errBudgetExceeded: seconds until
+// the next JST day
+func retryAfter(now time.Time) string {
+	now = now.In(jst)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, jst)
+	return strconv.Itoa(int(tomorrow.Sub(now).Seconds()) + 1)
+}
+
+// AgentUsage reports the LLM usage of the agent endpoints
+// @Summary Report agent token usage and cost
+// @Description Token usage and estimated cost of the agent endpoints per JST day and caller (an unauthenticated fingerprint of X-API-Key), with the configured budget; usage is kept in memory and lost on restart
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin API key"
+// @Param day query string false "Only this day (YYYY-MM-DD)" example("2024-01-15")
+// @Success 200 {object} AgentUsageResponse
+// @Failure 400 {string} string "Bad request - invalid day"
+// @Router /admin/agent/usage [get]
+func AgentUsage(w http.ResponseWriter, r *http.Request) {
+	day := r.URL.Query().Get("day")
+	if day != "" {
+		if _, err := time.Parse(time.DateOnly, day); err != nil {
+			http.Error(w, "Invalid day", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, agentUsage.report(day))
+}
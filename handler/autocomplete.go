@@ -1,26 +1,39 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"time"
 
 	"transit-api/cache"
+	"transit-api/kana"
+	"transit-api/locale"
 	"transit-api/model"
+	"transit-api/normalize"
 	"transit-api/utils"
-	
+
 	"github.com/jtclarkjr/router-go/middleware"
 )
 
 // Autocomplete cache with 30 day TTL, max 5000 entries
-// Cache key format: "word|lang"
+// Cache key format: "word|locale|romaji_style"
+// The word in the key is normalized, so width and kana variants share an entry
 // Station names don't change, so long TTL is appropriate
 var autocompleteCache = cache.NewLRUCache(5000, 30*24*time.Hour)
 
+// Empty results are cached briefly so typos don't occupy 30-day slots
+const emptyAutocompleteTTL = time.Hour
+
+// emptyAutocompleteBody is the encoded response when no station matched
+var emptyAutocompleteBody, _ = json.Marshal(model.FilteredAutocompleteResponse{})
+
 // Single flight to prevent duplicate in-flight requests
 var autocompleteSF = middleware.NewSingleFlight()
 
@@ -31,66 +44,94 @@ var autocompleteSF = middleware.NewSingleFlight()
 // @Accept json
 // @Produce json
 // @Param word query string true "Search word for station names" example("東京")
-// @Param lang query string false "Language for response (en for English/Romaji)" example("en")
+// @Param lang query string false "Language for response (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language" example("en")
+// @Param romaji_style query string false "Romanization style for Romaji names (hepburn, passport, passport-oh, kunrei, wapuro)" example("hepburn")
+// @Param Accept-Language header string false "Preferred languages with quality values" example("en-US,en;q=0.9")
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} model.FilteredAutocompleteResponse "Successful response with station suggestions"
+// @Success 304 {string} string "Not modified - If-None-Match matched the current ETag"
 // @Failure 400 {string} string "Bad request - missing or invalid parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Router /autocomplete [get]
 func Autocomplete(w http.ResponseWriter, r *http.Request) {
 	key := os.Getenv("RAPIDAPI_KEY")
 	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
-	lang := r.URL.Query().Get("lang")
-
-	word := r.URL.Query().Get("word")
+	loc := locale.FromRequest(r)
+	lang := loc.String()
 
-	// Check cache first
-	cacheKey := fmt.Sprintf("%s|%s", word, lang)
-	if cached, ok := autocompleteCache.Get(cacheKey); ok {
-		log.Printf("[CACHE HIT] Autocomplete: key=%s", cacheKey)
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "HIT")
-		_, err := w.Write(cached.([]byte))
-		if err != nil {
-			log.Printf("Error writing cached response: %v", err)
-		}
+	word := normalize.Query(r.URL.Query().Get("word"))
+	if word == "" {
+		writeError(w, loc, http.StatusBadRequest, locale.MsgMissingParameter, nil)
+		return
+	}
+	style, ok := romajiStyleFromRequest(r)
+	if !ok {
+		writeError(w, loc, http.StatusBadRequest, locale.MsgInvalidParameter, nil)
 		return
 	}
 
-	// Use single flight to prevent duplicate in-flight requests
-	log.Printf("[CACHE MISS] Autocomplete: key=%s, calling API...", cacheKey)
-	result, err := autocompleteSF.Do(cacheKey, func() ([]byte, error) {
-		return fetchAutocomplete(word, lang, key, host)
-	})
-
+	autocompleteDemand.Add(autocompleteDemandKey(word, lang))
+	result, expiresAt, cacheStatus, err := autocompleteResults(word, lang, style, key, host)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, loc, http.StatusInternalServerError, locale.MsgUpstreamFailed, err)
 		return
 	}
+	writeCachedJSON(w, r, loc, result, expiresAt, cacheStatus)
+}
+
+// autocompleteResults returns the cached suggestions for word, fetching them
+// on a miss, along with the entry's expiry and the X-Cache status
+// The cache key uses the normalized word; upstream gets the word as typed
+func autocompleteResults(word, lang string, style kana.Style, key, host string) ([]byte, time.Time, string, error) {
+	// Check cache first
+	cacheKey := autocompleteCacheKey(word, lang, style)
+	if cached, expiresAt, ok := autocompleteCache.GetWithExpiry(cacheKey); ok {
+		log.Printf("[CACHE HIT] Autocomplete: key=%s", cacheKey)
+		return cached.([]byte), expiresAt, "HIT", nil
+	}
 
-	// Cache and return the result
-	autocompleteCache.Set(cacheKey, result)
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
-	_, err = w.Write(result)
+	log.Printf("[CACHE MISS] Autocomplete: key=%s, calling API...", cacheKey)
+	result, err := refreshAutocomplete(cacheKey, word, lang, style, key, host)
 	if err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		return nil, time.Time{}, "", err
 	}
+
+	// An uncached miss has no expiry, so clients are told not to keep it
+	_, expiresAt, _ := autocompleteCache.Peek(cacheKey)
+	return result, expiresAt, "MISS", nil
 }
 
-// fetchAutocomplete performs the actual API call and processing
-func fetchAutocomplete(word, lang, key, host string) ([]byte, error) {
-	url := fmt.Sprintf(
+// refreshAutocomplete fetches the suggestions for cacheKey and caches them
+// Single flight shares one upstream call between concurrent misses, and only
+// the caller that made it stores the result
+func refreshAutocomplete(cacheKey, word, lang string, style kana.Style, key, host string) ([]byte, error) {
+	return autocompleteSF.Do(cacheKey, func() ([]byte, error) {
+		result, err := fetchAutocomplete(word, lang, style, key, host)
+		if err != nil {
+			return nil, err
+		}
+
+		// A miss only shows that this spelling found nothing, so it is cached for
+		// the other spellings of the key only when the key's own spelling was sent
+		if !bytes.Equal(result, emptyAutocompleteBody) || missIsShared(word) {
+			storeAutocomplete(cacheKey, result)
+		}
+		return result, nil
+	})
+}
+
+// fetchAutocompleteStations calls the upstream autocomplete API for word
+func fetchAutocompleteStations(word, key, host string) ([]model.AutocompleteStation, error) {
+	requestURL := fmt.Sprintf(
 		"https://%s/transport_node/autocomplete?word=%s&word_match=prefix",
 		host,
-		word,
+		url.QueryEscape(word),
 	)
 
-	log.Printf("[API CALL] Autocomplete: word=%s, lang=%s", word, lang)
-	
 	// Rate limit external API call
 	middleware.SharedAPIRateLimiter.Wait()
-	
-	req, err := http.NewRequest("GET", url, nil)
+
+	req, err := http.NewRequest("GET", requestURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -117,44 +158,109 @@ func fetchAutocomplete(word, lang, key, host string) ([]byte, error) {
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
+	return response.Items, nil
+}
 
-	// If the language is English, translate station names to Romaji
-	if lang == "en" {
-		var filteredItemsEn []model.FilteredStation
-		for _, item := range response.Items {
-			if len(item.Types) > 0 {
-				item.Type = item.Types[0]
-			}
-			if item.Type == "station" {
-				name := item.Ruby
-				romajiValue, err := utils.KanjiToRomaji(name)
+// autocompleteCacheKey builds the autocomplete cache key ("word|locale|romaji_style")
+// with the word normalized
+func autocompleteCacheKey(word, lang string, style kana.Style) string {
+	return fmt.Sprintf("%s|%s|%s", normalize.Station(word), lang, style)
+}
+
+// missIsShared reports whether an empty result for word holds for every
+// spelling with its cache key: romaji and kana are looked up by the readings
+// of the key, and other input by its own spelling only when that is the key's
+func missIsShared(word string) bool {
+	if _, isReading := stationReadings(word); isReading {
+		return true
+	}
+	return normalize.Query(word) == normalize.Station(word)
+}
+
+// autocompleteDemandKey builds the demand key ("word|locale") used for prewarming
+// with the word normalized
+func autocompleteDemandKey(word, lang string) string {
+	return fmt.Sprintf("%s|%s", normalize.Station(word), lang)
+}
+
+// romajiStyleFromRequest parses the optional romaji_style query parameter
+func romajiStyleFromRequest(r *http.Request) (kana.Style, bool) {
+	style := r.URL.Query().Get("romaji_style")
+	if style == "" {
+		return "", true
+	}
+	return kana.ParseStyle(style)
+}
+
+// storeAutocomplete caches a result, using a short TTL for empty results
+func storeAutocomplete(cacheKey string, result []byte) {
+	if bytes.Equal(result, emptyAutocompleteBody) {
+		autocompleteCache.SetWithTTL(cacheKey, result, emptyAutocompleteTTL)
+	} else {
+		autocompleteCache.Set(cacheKey, result)
+	}
+}
+
+// fetchAutocomplete performs the actual API call and processing
+// Romaji or kana input is looked up by its reading and matched against each
+// station's ruby, so "shinjuku" and "しんじゅく" both find 新宿
+func fetchAutocomplete(word, lang string, style kana.Style, key, host string) ([]byte, error) {
+	var stations []model.AutocompleteStation
+	var err error
+	if readings, isReading := stationReadings(word); isReading {
+		log.Printf("[API CALL] Autocomplete: readings=%v, lang=%s", readings, lang)
+		stations, _, err = fetchStationsByReading(readings, key, host)
+	} else {
+		log.Printf("[API CALL] Autocomplete: word=%s, lang=%s", word, lang)
+		stations, err = fetchAutocompleteStations(word, key, host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Nodes of the same station complex are collapsed into one item
+	complexes := groupStations(stationsOnly(stations))
+
+	// For supported languages, translate station names using the bundled
+	// dictionary, falling back to Romaji of the reading
+	translator, translate := utils.TranslatorFor(lang)
+	var filteredItems []model.FilteredStation
+	for _, group := range complexes {
+		item := model.FilteredStation{ID: group.ID, Name: group.Name, Type: "station"}
+		if len(group.Members) > 1 {
+			item.Members = slices.Clone(group.Members)
+		}
+
+		if translate {
+			// Prefer the dictionary name, then the romanized reading
+			romajiValue, ok := translator.Lookup(group.ID, group.Name)
+			if ok {
+				romajiValue = utils.StyleOfficialName(romajiValue, style)
+			} else if style != "" {
+				// The ruby is kana, so it is romanized in the style directly
+				romajiValue = kana.Romanize(group.Ruby, style)
+			} else {
+				var err error
+				romajiValue, err = utils.KanjiToRomaji(group.Ruby)
 				if err != nil {
 					return nil, fmt.Errorf("failed to translate station name: %w", err)
 				}
-				filteredItemsEn = append(filteredItemsEn, model.FilteredStation{
-					ID:   item.ID,
-					Name: romajiValue,
-					Type: item.Type,
-				})
 			}
-		}
-		filteredResponse := model.FilteredAutocompleteResponse{Items: filteredItemsEn}
-		return json.Marshal(filteredResponse)
-	}
+			item.Name = romajiValue
 
-	// Non-English: filter stations only
-	var filteredItems []model.FilteredStation
-	for _, item := range response.Items {
-		if len(item.Types) > 0 {
-			item.Type = item.Types[0]
-		}
-		if item.Type == "station" {
-			filteredItems = append(filteredItems, model.FilteredStation{
-				ID:   item.ID,
-				Name: item.Name,
-				Type: item.Type,
-			})
+			// Operators and prefectures are translated when the dictionary knows them
+			for i := range item.Members {
+				for _, field := range []*string{&item.Members[i].Operator, &item.Members[i].Prefecture} {
+					if *field == "" {
+						continue
+					}
+					if translated, ok := translator.Lookup("", *field); ok {
+						*field = utils.StyleOfficialName(translated, style)
+					}
+				}
+			}
 		}
+		filteredItems = append(filteredItems, item)
 	}
 
 	filteredResponse := model.FilteredAutocompleteResponse{Items: filteredItems}
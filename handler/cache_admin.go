@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"transit-api/cache"
+)
+
+// Named response caches exposed through the metrics and admin endpoints
+var caches = map[string]*cache.LRUCache{
+	"transit":      responseCache,
+	"autocomplete": autocompleteCache,
+	"agent":        agentCache,
+	"moderation":   moderationCache,
+}
+
+// StartCacheJanitors starts a background expiry sweep on every response cache
+func StartCacheJanitors(interval time.Duration) {
+	for _, c := range caches {
+		c.StartJanitor(interval)
+	}
+}
+
+// CloseCaches stops the background expiry sweeps
+func CloseCaches() {
+	for _, c := range caches {
+		c.Close()
+	}
+}
+
+// CacheEntry represents a single cache entry returned by the admin API
+type CacheEntry struct {
+	Key       string          `json:"key"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Value     json.RawMessage `json:"value" swaggertype:"object"`
+}
+
+// CacheKeysResponse represents the list of keys in a cache
+type CacheKeysResponse struct {
+	Cache string   `json:"cache"`
+	Keys  []string `json:"keys"`
+}
+
+// CachePurgeResponse reports how many entries were removed
+type CachePurgeResponse struct {
+	Cache   string `json:"cache"`
+	Removed int    `json:"removed"`
+}
+
+// CacheMetrics reports hit/miss/expiration/eviction counters for each cache
+// @Summary Get cache metrics
+// @Description Returns counters and sizes for the transit and autocomplete response caches
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]cache.Stats "Counters keyed by cache name"
+// @Router /metrics/cache [get]
+func CacheMetrics(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]cache.Stats, len(caches))
+	for name, c := range caches {
+		stats[name] = c.Stats()
+	}
+	writeJSON(w, stats)
+}
+
+// AdminOnly guards admin routes with the ADMIN_API_KEY environment variable
+// Admin routes are disabled entirely when ADMIN_API_KEY is not set
+func AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminKey := os.Getenv("ADMIN_API_KEY")
+		if adminKey == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+		provided := r.Header.Get("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// CacheKeys lists the live keys of a cache, optionally filtered by prefix
+// @Summary List cache keys
+// @Description Lists live keys in the named cache, optionally filtered by prefix
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin API key"
+// @Param cache query string true "Cache name (transit, autocomplete, agent or moderation)"
+// @Param prefix query string false "Key prefix" example("新宿駅|")
+// @Success 200 {object} CacheKeysResponse
+// @Failure 400 {string} string "Bad request - unknown cache"
+// @Router /admin/cache/keys [get]
+func CacheKeys(w http.ResponseWriter, r *http.Request) {
+	name, c, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	writeJSON(w, CacheKeysResponse{Cache: name, Keys: c.Keys(prefix)})
+}
+
+// CacheGetEntry returns a single cache entry
+// @Summary Get cache entry
+// @Description Returns the cached value and expiry for a key
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin API key"
+// @Param cache query string true "Cache name (transit, autocomplete, agent or moderation)"
+// @Param key query string true "Cache key"
+// @Success 200 {object} CacheEntry
+// @Failure 400 {string} string "Bad request - unknown cache or missing key"
+// @Failure 404 {string} string "Key not found"
+// @Router /admin/cache/entry [get]
+func CacheGetEntry(w http.ResponseWriter, r *http.Request) {
+	_, c, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	value, expiresAt, found := c.Peek(key)
+	if !found {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	body, _ := value.([]byte)
+	if !json.Valid(body) {
+		// Fall back to a JSON string so the envelope stays valid
+		body, _ = json.Marshal(string(body))
+	}
+	writeJSON(w, CacheEntry{Key: key, ExpiresAt: expiresAt, Value: body})
+}
+
+// CacheDeleteEntry removes a single key from a cache
+// @Summary Delete cache entry
+// @Description Removes a single key from the named cache
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin API key"
+// @Param cache query string true "Cache name (transit, autocomplete, agent or moderation)"
+// @Param key query string true "Cache key"
+// @Success 200 {object} CachePurgeResponse
+// @Failure 400 {string} string "Bad request - unknown cache or missing key"
+// @Router /admin/cache/entry [delete]
+func CacheDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	name, c, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	removed := 0
+	if c.Delete(key) {
+		removed = 1
+	}
+	log.Printf("[CACHE PURGE] %s: key=%s removed=%d", name, key, removed)
+	writeJSON(w, CachePurgeResponse{Cache: name, Removed: removed})
+}
+
+// CachePurge removes every key matching a glob pattern
+// @Summary Purge cache by pattern
+// @Description Removes every key matching a glob pattern, e.g. 新宿駅|*
+// @Tags admin
+// @Produce json
+// @Param X-Admin-Key header string true "Admin API key"
+// @Param cache query string true "Cache name (transit, autocomplete, agent or moderation)"
+// @Param pattern query string true "Glob pattern" example("新宿駅|*")
+// @Success 200 {object} CachePurgeResponse
+// @Failure 400 {string} string "Bad request - unknown cache or invalid pattern"
+// @Router /admin/cache/keys [delete]
+func CachePurge(w http.ResponseWriter, r *http.Request) {
+	name, c, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		http.Error(w, "pattern is required", http.StatusBadRequest)
+		return
+	}
+
+	removed, err := c.DeleteMatching(pattern)
+	if err != nil {
+		http.Error(w, "Invalid pattern", http.StatusBadRequest)
+		return
+	}
+	log.Printf("[CACHE PURGE] %s: pattern=%s removed=%d", name, pattern, removed)
+	writeJSON(w, CachePurgeResponse{Cache: name, Removed: removed})
+}
+
+// lookupCache resolves the cache query parameter, writing a 400 if unknown
+func lookupCache(w http.ResponseWriter, r *http.Request) (string, *cache.LRUCache, bool) {
+	name := r.URL.Query().Get("cache")
+	c, ok := caches[name]
+	if !ok {
+		http.Error(w, "Unknown cache: must be transit, autocomplete, agent or moderation", http.StatusBadRequest)
+		return "", nil, false
+	}
+	return name, c, true
+}
+
+// writeJSON encodes v as a JSON response body
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
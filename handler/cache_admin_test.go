@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAdminOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		adminKey string
+		header   string
+		want     int
+	}{
+		{name: "disabled without ADMIN_API_KEY", header: "secret", want: http.StatusForbidden},
+		{name: "missing key", adminKey: "secret", want: http.StatusUnauthorized},
+		{name: "wrong key", adminKey: "secret", header: "guess", want: http.StatusUnauthorized},
+		{name: "prefix of key", adminKey: "secret", header: "sec", want: http.StatusUnauthorized},
+		{name: "correct key", adminKey: "secret", header: "secret", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", tt.adminKey)
+			called := false
+			guarded := AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/cache/keys", nil)
+			if tt.header != "" {
+				r.Header.Set("X-Admin-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+			guarded(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if called != (tt.want == http.StatusNoContent) {
+				t.Errorf("handler called = %v", called)
+			}
+		})
+	}
+}
+
+// adminRequest runs handler on a request with query parameters
+func adminRequest(handler http.HandlerFunc, method string, query url.Values) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(method, "/admin/cache?"+query.Encode(), nil))
+	return w
+}
+
+func TestCacheAdmin(t *testing.T) {
+	autocompleteCache.Clear()
+	t.Cleanup(autocompleteCache.Clear)
+	autocompleteCache.Set("新宿駅|ja", []byte(`[{"id":"00000001"}]`))
+	autocompleteCache.Set("新宿三丁目駅|ja", []byte("not json"))
+	autocompleteCache.Set("渋谷駅|ja", []byte(`[]`))
+
+	t.Run("unknown cache", func(t *testing.T) {
+		w := adminRequest(CacheKeys, http.MethodGet, url.Values{"cache": {"missing"}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("keys by prefix", func(t *testing.T) {
+		w := adminRequest(CacheKeys, http.MethodGet, url.Values{"cache": {"autocomplete"}, "prefix": {"新宿"}})
+		var got CacheKeysResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid body %q: %v", w.Body, err)
+		}
+		slices.Sort(got.Keys)
+		want := []string{"新宿三丁目駅|ja", "新宿駅|ja"}
+		if got.Cache != "autocomplete" || !slices.Equal(got.Keys, want) {
+			t.Errorf("got %+v, want keys %q", got, want)
+		}
+	})
+
+	t.Run("entry", func(t *testing.T) {
+		w := adminRequest(CacheGetEntry, http.MethodGet, url.Values{"cache": {"autocomplete"}, "key": {"新宿駅|ja"}})
+		var got CacheEntry
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid body %q: %v", w.Body, err)
+		}
+		if string(got.Value) != `[{"id":"00000001"}]` {
+			t.Errorf("value = %s", got.Value)
+		}
+		if !got.ExpiresAt.After(time.Now()) {
+			t.Errorf("expires_at = %v, want in the future", got.ExpiresAt)
+		}
+	})
+
+	t.Run("entry that isn't JSON", func(t *testing.T) {
+		w := adminRequest(CacheGetEntry, http.MethodGet, url.Values{"cache": {"autocomplete"}, "key": {"新宿三丁目駅|ja"}})
+		var got CacheEntry
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid body %q: %v", w.Body, err)
+		}
+		if string(got.Value) != `"not json"` {
+			t.Errorf("value = %s, want a JSON string", got.Value)
+		}
+	})
+
+	t.Run("missing entry", func(t *testing.T) {
+		w := adminRequest(CacheGetEntry, http.MethodGet, url.Values{"cache": {"autocomplete"}, "key": {"池袋駅|ja"}})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		w := adminRequest(CachePurge, http.MethodDelete, url.Values{"cache": {"autocomplete"}, "pattern": {"新宿["}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("purge by pattern", func(t *testing.T) {
+		w := adminRequest(CachePurge, http.MethodDelete, url.Values{"cache": {"autocomplete"}, "pattern": {"新宿*|ja"}})
+		var got CachePurgeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid body %q: %v", w.Body, err)
+		}
+		if got.Removed != 2 {
+			t.Errorf("removed = %d, want 2", got.Removed)
+		}
+		if keys := autocompleteCache.Keys(""); !slices.Equal(keys, []string{"渋谷駅|ja"}) {
+			t.Errorf("keys after purge = %q", keys)
+		}
+	})
+
+	t.Run("delete entry", func(t *testing.T) {
+		w := adminRequest(CacheDeleteEntry, http.MethodDelete, url.Values{"cache": {"autocomplete"}, "key": {"渋谷駅|ja"}})
+		var got CachePurgeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid body %q: %v", w.Body, err)
+		}
+		if got.Removed != 1 || autocompleteCache.Len() != 0 {
+			t.Errorf("removed = %d, len = %d", got.Removed, autocompleteCache.Len())
+		}
+	})
+}
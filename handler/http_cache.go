@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"transit-api/locale"
+)
+
+// etagFor returns a strong ETag derived from the response body
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag
+// If-None-Match uses weak comparison, so W/ prefixes are ignored
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setLocaleHeaders echoes the resolved locale and marks the response as
+// varying by Accept-Language so shared caches key on it
+func setLocaleHeaders(w http.ResponseWriter, loc locale.Locale) {
+	w.Header().Set("Content-Language", loc.String())
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// writeError writes a localized error message and logs the underlying error
+func writeError(w http.ResponseWriter, loc locale.Locale, status int, id locale.MessageID, err error) {
+	if err != nil {
+		log.Printf("Error (%s): %v", id, err)
+	}
+	setLocaleHeaders(w, loc)
+	http.Error(w, locale.Message(loc, id), status)
+}
+
+// writeCachedJSON writes a cached JSON body with ETag and Cache-Control headers
+// Cache-Control max-age matches the remaining server-side TTL, and a matching
+// If-None-Match is answered with 304 Not Modified
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, loc locale.Locale, body []byte, expiresAt time.Time, cacheStatus string) {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	etag := etagFor(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("X-Cache", cacheStatus)
+	setLocaleHeaders(w, loc)
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"transit-api/locale"
+	"transit-api/utils"
+)
+
+// Every response that depends on the negotiated locale, including errors and
+// 304s, names it in Content-Language and varies by Accept-Language
+func TestLocaleHeaders(t *testing.T) {
+	body := []byte(`{"items":[]}`)
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		loc   locale.Locale
+		want  int
+	}{
+		{
+			name: "cached JSON",
+			write: func(w http.ResponseWriter) {
+				writeCachedJSON(w, httptest.NewRequest("GET", "/transit", nil), locale.Korean, body, time.Now().Add(time.Minute), "HIT")
+			},
+			loc:  locale.Korean,
+			want: http.StatusOK,
+		},
+		{
+			name: "not modified",
+			write: func(w http.ResponseWriter) {
+				r := httptest.NewRequest("GET", "/transit", nil)
+				r.Header.Set("If-None-Match", etagFor(body))
+				writeCachedJSON(w, r, locale.TraditionalChinese, body, time.Now().Add(time.Minute), "HIT")
+			},
+			loc:  locale.TraditionalChinese,
+			want: http.StatusNotModified,
+		},
+		{
+			name: "error",
+			write: func(w http.ResponseWriter) {
+				writeError(w, locale.English, http.StatusBadRequest, locale.MsgMissingParameter, nil)
+			},
+			loc:  locale.English,
+			want: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.write(w)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.loc.String() {
+				t.Errorf("Content-Language = %q, want %q", got, tt.loc)
+			}
+			if vary := w.Header().Values("Vary"); !slices.Contains(vary, "Accept-Language") {
+				t.Errorf("Vary = %q, want Accept-Language", vary)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	writeError(w, locale.SimplifiedChinese, http.StatusNotFound, locale.MsgStationNotFound, nil)
+	if got, want := w.Body.String(), locale.Message(locale.SimplifiedChinese, locale.MsgStationNotFound)+"\n"; got != want {
+		t.Errorf("error body = %q, want %q", got, want)
+	}
+}
+
+// /transit and /autocomplete tag the cached body, answer a matching
+// If-None-Match with 304 and let clients cache for the entry's remaining TTL
+func TestCachedResponseHeaders(t *testing.T) {
+	transitBody := []byte(`{"items":[{"summary":{"move":{"time":30}}}]}`)
+	autocompleteBody := []byte(`{"items":[{"id":"00006668","name":"東京","type":"station"}]}`)
+
+	transitRequest := httptest.NewRequest("GET", "/transit?start=東京&goal=新宿&start_time=2024-01-15T09:00:00", nil)
+	routes, ok := routeQueryFromRequest(transitRequest)
+	if !ok {
+		t.Fatal("invalid transit request")
+	}
+	style, _ := romajiStyleFromRequest(transitRequest)
+	transitKey := transitCacheKey(stationChoiceFromRequest(transitRequest, "start"), stationChoiceFromRequest(transitRequest, "goal"), routes, utils.TranslateOptions{Lang: "ja", Style: style})
+	responseCache.SetWithTTL(transitKey, transitBody, 90*time.Second)
+	t.Cleanup(responseCache.Clear)
+
+	autocompleteCache.SetWithTTL(autocompleteCacheKey("東京", "ja", style), autocompleteBody, 2*time.Hour)
+	t.Cleanup(autocompleteCache.Clear)
+
+	endpoints := []struct {
+		name    string
+		target  string
+		handler http.HandlerFunc
+		body    []byte
+		ttl     time.Duration
+	}{
+		{name: "transit", target: transitRequest.URL.String(), handler: Transit(), body: transitBody, ttl: 90 * time.Second},
+		{name: "autocomplete", target: "/autocomplete?word=東京", handler: Autocomplete, body: autocompleteBody, ttl: 2 * time.Hour},
+	}
+	for _, endpoint := range endpoints {
+		etag := etagFor(endpoint.body)
+		tests := []struct {
+			name        string
+			ifNoneMatch string
+			want        int
+		}{
+			{name: "no validator", want: http.StatusOK},
+			{name: "exact", ifNoneMatch: etag, want: http.StatusNotModified},
+			{name: "weak", ifNoneMatch: "W/" + etag, want: http.StatusNotModified},
+			{name: "list", ifNoneMatch: `"0123456789abcdef", ` + etag, want: http.StatusNotModified},
+			{name: "wildcard", ifNoneMatch: "*", want: http.StatusNotModified},
+			{name: "stale", ifNoneMatch: `"0123456789abcdef"`, want: http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(endpoint.name+"/"+tt.name, func(t *testing.T) {
+				r := httptest.NewRequest("GET", endpoint.target, nil)
+				if tt.ifNoneMatch != "" {
+					r.Header.Set("If-None-Match", tt.ifNoneMatch)
+				}
+				w := httptest.NewRecorder()
+				endpoint.handler(w, r)
+
+				if w.Code != tt.want {
+					t.Fatalf("status = %d, want %d", w.Code, tt.want)
+				}
+				if got := w.Header().Get("ETag"); got != etag {
+					t.Errorf("ETag = %q, want %q", got, etag)
+				}
+				if got := w.Header().Get("X-Cache"); got != "HIT" {
+					t.Errorf("X-Cache = %q, want HIT", got)
+				}
+				var maxAge int
+				if _, err := fmt.Sscanf(w.Header().Get("Cache-Control"), "public, max-age=%d", &maxAge); err != nil {
+					t.Fatalf("Cache-Control = %q: %v", w.Header().Get("Cache-Control"), err)
+				}
+				if ttl := int(endpoint.ttl.Seconds()); maxAge > ttl || maxAge < ttl-5 {
+					t.Errorf("max-age = %d, want the remaining %d seconds", maxAge, ttl)
+				}
+				if tt.want == http.StatusNotModified {
+					if w.Body.Len() != 0 {
+						t.Errorf("304 body = %q, want empty", w.Body.String())
+					}
+				} else if !bytes.Equal(w.Body.Bytes(), endpoint.body) {
+					t.Errorf("body = %s, want %s", w.Body.Bytes(), endpoint.body)
+				}
+			})
+		}
+	}
+}
+
+// max-age counts down with the entry and stops at 0 once it has expired
+func TestCachedResponseMaxAge(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      string
+	}{
+		{name: "remaining", expiresAt: time.Now().Add(10*time.Minute + 500*time.Millisecond), want: "public, max-age=600"},
+		{name: "expiring", expiresAt: time.Now().Add(500 * time.Millisecond), want: "public, max-age=0"},
+		{name: "expired", expiresAt: time.Now().Add(-time.Minute), want: "public, max-age=0"},
+		{name: "uncached", expiresAt: time.Time{}, want: "public, max-age=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeCachedJSON(w, httptest.NewRequest("GET", "/autocomplete", nil), locale.Japanese, []byte(`{"items":[]}`), tt.expiresAt, "MISS")
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
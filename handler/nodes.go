@@ -6,21 +6,29 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"sync"
+
 	"transit-api/model"
-	
+	"transit-api/normalize"
+
 	"github.com/jtclarkjr/router-go/middleware"
 )
 
 // Permanent cache for station name -> node ID mapping
 // Node IDs never change, so no TTL needed
+// Keys are normalized names, so every spelling shares one entry
 var nodeCache sync.Map
 
 // Used to GET nodeIds for transit request
 func fetchNodes(station string, channel chan<- string) {
+	// Upstream gets the name as typed; the cache key is normalized
+	cacheKey := normalize.Station(station)
+	station = normalize.Query(station)
+
 	// Check cache first
-	if cached, ok := nodeCache.Load(station); ok {
+	if cached, ok := nodeCache.Load(cacheKey); ok {
 		channel <- cached.(string)
 		return
 	}
@@ -28,13 +36,13 @@ func fetchNodes(station string, channel chan<- string) {
 	key := os.Getenv("RAPIDAPI_KEY")
 	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
 
-	url := fmt.Sprintf("https://%s/transport_node?word=%s&limit=1", host, station)
-	// log.Printf("Fetching node for station: %s, URL: %s", station, url)
+	requestURL := fmt.Sprintf("https://%s/transport_node?word=%s&limit=1", host, url.QueryEscape(station))
+	// log.Printf("Fetching node for station: %s, URL: %s", station, requestURL)
 
 	// Rate limit external API call
 	middleware.SharedAPIRateLimiter.Wait()
 
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequest("GET", requestURL, nil)
 	if err != nil {
 		log.Printf("Error creating request for station %s: %v", station, err)
 		channel <- ""
@@ -89,7 +97,7 @@ func fetchNodes(station string, channel chan<- string) {
 	}
 
 	// Cache the node ID for future requests
-	nodeCache.Store(station, nodeId)
+	nodeCache.Store(cacheKey, nodeId)
 
 	// log.Printf("Found node ID for station %s: %s", station, nodeId)
 	channel <- nodeId
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jtclarkjr/router-go/middleware"
+)
+
+// roundTripFunc stubs the upstream API
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubUpstream answers every upstream request with body and records the
+// request URIs
+func stubUpstream(t *testing.T, body string) *[]string {
+	t.Helper()
+	t.Setenv("RAPIDAPI_TRANSPORT_HOST", "upstream.test")
+	var mu sync.Mutex
+	var requests []string
+	transport := middleware.SharedHTTPClient.Transport
+	middleware.SharedHTTPClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requests = append(requests, r.URL.RequestURI())
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+	})
+	t.Cleanup(func() { middleware.SharedHTTPClient.Transport = transport })
+	return &requests
+}
+
+func TestFetchNodesUpstreamQuery(t *testing.T) {
+	requests := stubUpstream(t, `{"items":[{"id":"00004254","name":"JR新宿駅"}]}`)
+	t.Cleanup(func() { nodeCache.Clear() })
+
+	channel := make(chan string, 1)
+	fetchNodes(" ＪＲ新宿駅 ", channel)
+	if got := <-channel; got != "00004254" {
+		t.Fatalf("node = %q, want 00004254", got)
+	}
+	// Upstream gets the NFKC name with its 駅; the cache key drops it
+	want := []string{"/transport_node?word=JR%E6%96%B0%E5%AE%BF%E9%A7%85&limit=1"}
+	if len(*requests) != 1 || (*requests)[0] != want[0] {
+		t.Errorf("upstream requests = %q, want %q", *requests, want)
+	}
+	if _, ok := nodeCache.Load("JR新宿"); !ok {
+		t.Error("node not cached under the normalized name")
+	}
+
+	// Another spelling of the same name is served from the cache
+	fetchNodes("JR新宿", channel)
+	if got := <-channel; got != "00004254" || len(*requests) != 1 {
+		t.Errorf("node = %q after %d requests, want a cache hit", got, len(*requests))
+	}
+}
+
+func TestAutocompleteUpstreamQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     string
+		body     string
+		cacheKey string
+		want     []string
+	}{
+		{
+			name:     "kanji is sent as typed",
+			word:     "ＪＲ　新宿駅",
+			body:     `{"items":[{"id":"00004254","name":"JR新宿","ruby":"じぇいあーるしんじゅく","types":["station"]}]}`,
+			cacheKey: "JR 新宿|ja|",
+			want:     []string{"/transport_node/autocomplete?word=JR+%E6%96%B0%E5%AE%BF%E9%A7%85&word_match=prefix"},
+		},
+		{
+			// Nothing matches しんじゅく here, so the long vowel reading is tried next
+			name:     "romaji is sent as its readings",
+			word:     "Shinjuku",
+			cacheKey: "shinjuku|ja|",
+			want: []string{
+				"/transport_node/autocomplete?word=%E3%81%97%E3%82%93%E3%81%98%E3%82%85%E3%81%8F&word_match=prefix",
+				"/transport_node/autocomplete?word=%E3%81%97%E3%82%93%E3%81%98%E3%82%85%E3%81%86%E3%81%8F&word_match=prefix",
+			},
+		},
+		{
+			// A miss stops after the three likeliest of tokyo's eight readings
+			name:     "romaji lookups are capped",
+			word:     "tokyo",
+			cacheKey: "tokyo|ja|",
+			want: []string{
+				"/transport_node/autocomplete?word=%E3%81%A8%E3%81%8D%E3%82%87%E3%81%86&word_match=prefix",
+				"/transport_node/autocomplete?word=%E3%81%A8%E3%81%86%E3%81%8D%E3%82%87%E3%81%86&word_match=prefix",
+				"/transport_node/autocomplete?word=%E3%81%A8%E3%81%8D%E3%82%87&word_match=prefix",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = `{"items":[]}`
+			}
+			requests := stubUpstream(t, body)
+			autocompleteCache.Clear()
+			t.Cleanup(autocompleteCache.Clear)
+
+			r := httptest.NewRequest("GET", "/autocomplete?lang=ja", nil)
+			query := r.URL.Query()
+			query.Set("word", tt.word)
+			r.URL.RawQuery = query.Encode()
+			w := httptest.NewRecorder()
+			Autocomplete(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if !slices.Equal(*requests, tt.want) {
+				t.Errorf("upstream requests = %q, want %q", *requests, tt.want)
+			}
+			if _, _, ok := autocompleteCache.Peek(tt.cacheKey); !ok {
+				t.Errorf("result not cached under %q", tt.cacheKey)
+			}
+		})
+	}
+}
+
+// Spellings sharing a cache key share the upstream answer that fills it,
+// but a miss is shared only when it came from the key's own spelling
+func TestAutocompleteSharedKey(t *testing.T) {
+	autocomplete := func(word string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/autocomplete?lang=ja", nil)
+		query := r.URL.Query()
+		query.Set("word", word)
+		r.URL.RawQuery = query.Encode()
+		w := httptest.NewRecorder()
+		Autocomplete(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", word, w.Code)
+		}
+		return w
+	}
+	t.Cleanup(autocompleteCache.Clear)
+
+	t.Run("hit", func(t *testing.T) {
+		autocompleteCache.Clear()
+		requests := stubUpstream(t, `{"items":[{"id":"00004254","name":"新宿","ruby":"しんじゅく","types":["station"]}]}`)
+		first := autocomplete("新宿駅")
+		second := autocomplete("新宿")
+		if len(*requests) != 1 {
+			t.Errorf("upstream requests = %q, want one", *requests)
+		}
+		if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() {
+			t.Errorf("second spelling got %s %q, want the first's body from the cache", second.Header().Get("X-Cache"), second.Body.String())
+		}
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		autocompleteCache.Clear()
+		requests := stubUpstream(t, `{"items":[]}`)
+		// 新宿駅 is not the key's spelling, so its miss is not cached
+		autocomplete("新宿駅")
+		autocomplete("新宿")
+		if len(*requests) != 2 {
+			t.Errorf("upstream requests = %q, want one per spelling", *requests)
+		}
+		// 新宿's own miss is
+		if w := autocomplete("新宿駅"); w.Header().Get("X-Cache") != "HIT" || len(*requests) != 2 {
+			t.Errorf("X-Cache = %s after %d requests, want the cached miss", w.Header().Get("X-Cache"), len(*requests))
+		}
+	})
+}
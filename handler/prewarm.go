@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"transit-api/cache"
+	"transit-api/locale"
+	"transit-api/normalize"
+	"transit-api/utils"
+)
+
+// Demand counters recorded from real traffic, used to pick prewarm targets
+// Transit demand is keyed by time of day ("start|goal|HH:MM|locale") so a
+// commute requested every morning accumulates across days
+var (
+	transitDemand      = cache.NewFrequencyCounter(10000)
+	autocompleteDemand = cache.NewFrequencyCounter(10000)
+)
+
+// Transit entries only live for 5 minutes, so each route is refreshed this
+// long before its departure time rather than in the daily run
+const transitPrewarmLead = 2 * time.Minute
+
+// Pause between prewarm calls so user traffic gets rate limiter slots first
+const prewarmPause = time.Second
+
+// Station times are Japan local time regardless of server timezone
+var jst = time.FixedZone("JST", 9*60*60)
+
+// PrewarmConfig configures the cache prewarm job
+type PrewarmConfig struct {
+	// At is the daily JST time (HH:MM) to refresh autocomplete entries and
+	// station node IDs, and to recompute the most frequent keys
+	At string `json:"at"`
+	// TopN is how many of the most frequent recorded keys to add to the list
+	TopN         int            `json:"top_n"`
+	Transit      []PrewarmRoute `json:"transit"`
+	Autocomplete []PrewarmWord  `json:"autocomplete"`
+}
+
+// PrewarmRoute is a transit query refreshed shortly before its departure time
+type PrewarmRoute struct {
+	Start string `json:"start"`
+	Goal  string `json:"goal"`
+	Time  string `json:"time"` // HH:MM, JST
+	Lang  string `json:"lang"`
+}
+
+// PrewarmWord is an autocomplete query refreshed in the daily run
+type PrewarmWord struct {
+	Word string `json:"word"`
+	Lang string `json:"lang"`
+}
+
+// LoadPrewarmConfig reads the prewarm list from PREWARM_FILE (JSON) if set
+// PREWARM_AT and PREWARM_TOP_N override the file values
+// The job is disabled when no daily time is configured
+func LoadPrewarmConfig() (PrewarmConfig, error) {
+	var config PrewarmConfig
+	if path := os.Getenv("PREWARM_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to read prewarm file: %w", err)
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("failed to parse prewarm file: %w", err)
+		}
+	}
+	if at := os.Getenv("PREWARM_AT"); at != "" {
+		config.At = at
+	}
+	if topN := os.Getenv("PREWARM_TOP_N"); topN != "" {
+		n, err := strconv.Atoi(topN)
+		if err != nil {
+			return config, fmt.Errorf("invalid PREWARM_TOP_N: %w", err)
+		}
+		config.TopN = n
+	}
+	if config.TopN < 0 {
+		return config, fmt.Errorf("invalid prewarm top_n %d: must not be negative", config.TopN)
+	}
+	if config.At != "" {
+		if _, err := time.Parse("15:04", config.At); err != nil {
+			return config, fmt.Errorf("invalid prewarm time %q: %w", config.At, err)
+		}
+	}
+	return config, nil
+}
+
+// prewarmer refreshes caches on a schedule
+type prewarmer struct {
+	config PrewarmConfig
+
+	mu           sync.Mutex
+	transit      []PrewarmRoute
+	autocomplete []PrewarmWord
+
+	// warm refreshes one route, pausing pause between routes in a batch
+	warm  func(route PrewarmRoute, departure time.Time)
+	pause time.Duration
+
+	dailyRunning   atomic.Bool
+	transitRunning atomic.Bool
+}
+
+// newPrewarmer creates a prewarmer that refreshes routes upstream
+func newPrewarmer(config PrewarmConfig) *prewarmer {
+	p := &prewarmer{config: config, pause: prewarmPause}
+	p.warm = p.warmTransit
+	return p
+}
+
+// StartPrewarm starts the prewarm scheduler in the background
+// It does nothing when config.At is empty
+func StartPrewarm(config PrewarmConfig) {
+	if config.At == "" {
+		return
+	}
+	p := newPrewarmer(config)
+	p.refreshPlan()
+	log.Printf("[PREWARM] Scheduled daily at %s JST, %d routes, %d words", config.At, len(p.transit), len(p.autocomplete))
+	go p.run()
+}
+
+// run ticks at the start of every minute
+func (p *prewarmer) run() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)))
+	p.tick(time.Now())
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		p.tick(now)
+	}
+}
+
+// tick starts the daily run when due and refreshes routes departing soon
+// Both run in the background so a slow batch never delays the next tick; a
+// batch still running when the next one is due makes that one skip
+func (p *prewarmer) tick(now time.Time) {
+	now = now.In(jst)
+
+	if now.Format("15:04") == p.config.At && p.dailyRunning.CompareAndSwap(false, true) {
+		go func() {
+			defer p.dailyRunning.Store(false)
+			p.refreshPlan()
+			p.warmDaily()
+		}()
+	}
+
+	departure, routes := p.dueRoutes(now)
+	if len(routes) == 0 {
+		return
+	}
+	if !p.transitRunning.CompareAndSwap(false, true) {
+		log.Printf("[PREWARM] Skipping %d routes departing %s: previous batch still running", len(routes), departure.Format("15:04"))
+		return
+	}
+	go func() {
+		defer p.transitRunning.Store(false)
+		for i, route := range routes {
+			if i > 0 {
+				time.Sleep(p.pause)
+			}
+			p.warm(route, departure)
+		}
+	}()
+}
+
+// dueRoutes returns the planned routes to refresh at now and their departure
+func (p *prewarmer) dueRoutes(now time.Time) (time.Time, []PrewarmRoute) {
+	departure := now.In(jst).Add(transitPrewarmLead).Truncate(time.Minute)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	routes := make([]PrewarmRoute, 0)
+	for _, route := range p.transit {
+		if route.Time == departure.Format("15:04") {
+			routes = append(routes, route)
+		}
+	}
+	return departure, routes
+}
+
+// refreshPlan merges the configured lists with the most frequent recorded keys
+func (p *prewarmer) refreshPlan() {
+	// Configured stations are sent upstream as typed, like live requests; the
+	// keys normalize them, so they are shared with live traffic.
+	// Recorded keys only hold the normalized names, which are sent as they are
+	transit := make([]PrewarmRoute, 0, len(p.config.Transit))
+	for _, route := range p.config.Transit {
+		route.Start = normalize.Query(route.Start)
+		route.Goal = normalize.Query(route.Goal)
+		route.Lang = prewarmLocale(route.Lang)
+		transit = append(transit, route)
+	}
+	autocomplete := make([]PrewarmWord, 0, len(p.config.Autocomplete))
+	for _, word := range p.config.Autocomplete {
+		word.Word = normalize.Query(word.Word)
+		word.Lang = prewarmLocale(word.Lang)
+		autocomplete = append(autocomplete, word)
+	}
+
+	seenRoutes := make(map[string]bool)
+	for _, route := range transit {
+		seenRoutes[transitDemandKey(route.Start, route.Goal, route.Time, route.Lang)] = true
+	}
+	for _, top := range transitDemand.Top(p.config.TopN) {
+		parts := strings.Split(top.Key, "|")
+		if len(parts) != 4 || seenRoutes[top.Key] {
+			continue
+		}
+		transit = append(transit, PrewarmRoute{Start: parts[0], Goal: parts[1], Time: parts[2], Lang: parts[3]})
+	}
+
+	seenWords := make(map[string]bool)
+	for _, word := range autocomplete {
+		seenWords[autocompleteDemandKey(word.Word, word.Lang)] = true
+	}
+	for _, top := range autocompleteDemand.Top(p.config.TopN) {
+		i := strings.LastIndex(top.Key, "|")
+		if i < 0 || seenWords[top.Key] {
+			continue
+		}
+		autocomplete = append(autocomplete, PrewarmWord{Word: top.Key[:i], Lang: top.Key[i+1:]})
+	}
+
+	p.mu.Lock()
+	p.transit = transit
+	p.autocomplete = autocomplete
+	p.mu.Unlock()
+}
+
+// warmDaily refreshes autocomplete entries and resolves node IDs for every
+// planned station so the first transit request skips the node lookups
+func (p *prewarmer) warmDaily() {
+	p.mu.Lock()
+	transit := p.transit
+	autocomplete := p.autocomplete
+	p.mu.Unlock()
+
+	log.Printf("[PREWARM] Daily run: %d words, %d routes", len(autocomplete), len(transit))
+
+	key := os.Getenv("RAPIDAPI_KEY")
+	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
+	for _, word := range autocomplete {
+		cacheKey := autocompleteCacheKey(word.Word, word.Lang, "")
+		if _, err := refreshAutocomplete(cacheKey, word.Word, word.Lang, "", key, host); err != nil {
+			log.Printf("[PREWARM] Autocomplete %s failed: %v", cacheKey, err)
+		}
+		time.Sleep(p.pause)
+	}
+
+	stations := make(map[string]bool)
+	for _, route := range transit {
+		stations[route.Start] = true
+		stations[route.Goal] = true
+	}
+	// Resolve the way transit requests do, so romaji and kana names warm the
+	// node of their canonical Japanese name
+	for station := range stations {
+		channel := make(chan string, 1)
+		resolveNode(stationChoice{Name: station}, channel)
+		<-channel
+		time.Sleep(p.pause)
+	}
+}
+
+// warmTransit refreshes the cache entry for a route departing at departure
+func (p *prewarmer) warmTransit(route PrewarmRoute, departure time.Time) {
+	startTime := departure.Format(transitTimeLayout)
+	options := utils.TranslateOptions{Lang: route.Lang}
+	start := stationChoice{Name: route.Start}
+	goal := stationChoice{Name: route.Goal}
+	routes := routeQuery{StartTime: startTime}
+	cacheKey := transitCacheKey(start, goal, routes, options)
+
+	key := os.Getenv("RAPIDAPI_KEY")
+	host := os.Getenv("RAPIDAPI_TRANSIT_HOST")
+	if _, err := refreshTransit(cacheKey, start, goal, routes, options, key, host); err != nil {
+		log.Printf("[PREWARM] Transit %s failed: %v", cacheKey, err)
+		return
+	}
+	log.Printf("[PREWARM] Transit %s refreshed", cacheKey)
+}
+
+// prewarmLocale normalizes a configured language to a supported locale tag
+func prewarmLocale(lang string) string {
+	if loc, ok := locale.Normalize(lang); ok {
+		return loc.String()
+	}
+	return locale.Default.String()
+}
+
+// transitDemandKey builds the time-of-day demand key ("start|goal|HH:MM|locale")
+// Station names are normalized so every spelling counts toward one key
+func transitDemandKey(startStation, endStation, timeOfDay, lang string) string {
+	return fmt.Sprintf("%s|%s|%s|%s", normalize.Station(startStation), normalize.Station(endStation), timeOfDay, lang)
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrewarmerDueRoutes(t *testing.T) {
+	p := newPrewarmer(PrewarmConfig{Transit: []PrewarmRoute{
+		{Start: "新宿", Goal: "東京", Time: "08:00", Lang: "ja"},
+		{Start: "渋谷", Goal: "品川", Time: "08:00", Lang: "en"},
+		{Start: "池袋", Goal: "上野", Time: "08:01", Lang: "ja"},
+	}})
+	p.refreshPlan()
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want []string
+	}{
+		{name: "lead time before departure", now: time.Date(2026, 10, 19, 7, 58, 0, 0, jst), want: []string{"新宿", "渋谷"}},
+		{name: "seconds are ignored", now: time.Date(2026, 10, 19, 7, 58, 59, 0, jst), want: []string{"新宿", "渋谷"}},
+		{name: "next minute", now: time.Date(2026, 10, 19, 7, 59, 0, 0, jst), want: []string{"池袋"}},
+		{name: "too early", now: time.Date(2026, 10, 19, 7, 57, 0, 0, jst), want: []string{}},
+		{name: "server clock in UTC", now: time.Date(2026, 10, 18, 22, 58, 0, 0, time.UTC), want: []string{"新宿", "渋谷"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			departure, routes := p.dueRoutes(tt.now)
+			starts := make([]string, 0, len(routes))
+			for _, route := range routes {
+				starts = append(starts, route.Start)
+			}
+			if !slices.Equal(starts, tt.want) {
+				t.Errorf("routes = %q, want %q", starts, tt.want)
+			}
+			if want := tt.now.In(jst).Add(transitPrewarmLead).Truncate(time.Minute); !departure.Equal(want) {
+				t.Errorf("departure = %v, want %v", departure, want)
+			}
+		})
+	}
+}
+
+// Routes are warmed in the background, and a batch due while the previous
+// one is still running is skipped
+func TestPrewarmerTick(t *testing.T) {
+	p := newPrewarmer(PrewarmConfig{Transit: []PrewarmRoute{
+		{Start: "新宿", Goal: "東京", Time: "08:00", Lang: "ja"},
+		{Start: "渋谷", Goal: "品川", Time: "08:00", Lang: "ja"},
+		{Start: "池袋", Goal: "上野", Time: "08:01", Lang: "ja"},
+	}})
+	p.refreshPlan()
+	p.pause = 0
+	warmed := make(chan string)
+	release := make(chan struct{})
+	p.warm = func(route PrewarmRoute, departure time.Time) {
+		warmed <- route.Start + "@" + departure.Format("15:04")
+		<-release
+	}
+
+	// tick returns while the first route is still warming
+	p.tick(time.Date(2026, 10, 19, 7, 58, 0, 0, jst))
+	if got := <-warmed; got != "新宿@08:00" {
+		t.Errorf("warmed %s, want 新宿@08:00", got)
+	}
+
+	// The 08:01 batch is skipped while 08:00 is running
+	p.tick(time.Date(2026, 10, 19, 7, 59, 0, 0, jst))
+	release <- struct{}{}
+	if got := <-warmed; got != "渋谷@08:00" {
+		t.Errorf("warmed %s, want 渋谷@08:00", got)
+	}
+	release <- struct{}{}
+
+	deadline := time.Now().Add(time.Second)
+	for p.transitRunning.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("batch did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Once the batch is done, the next due batch runs
+	p.tick(time.Date(2026, 10, 19, 7, 59, 0, 0, jst))
+	if got := <-warmed; got != "池袋@08:01" {
+		t.Errorf("warmed %s, want 池袋@08:01", got)
+	}
+	release <- struct{}{}
+}
+
+func TestLoadPrewarmConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		topN    string
+		want    int
+		wantErr bool
+	}{
+		{name: "file", file: `{"at":"04:00","top_n":50}`, want: 50},
+		{name: "env overrides file", file: `{"at":"04:00","top_n":50}`, topN: "10", want: 10},
+		{name: "negative env", topN: "-1", wantErr: true},
+		{name: "negative file", file: `{"at":"04:00","top_n":-1}`, wantErr: true},
+		{name: "not a number", topN: "ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "prewarm.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PREWARM_FILE", path)
+			t.Setenv("PREWARM_AT", "")
+			t.Setenv("PREWARM_TOP_N", tt.topN)
+
+			config, err := LoadPrewarmConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && config.TopN != tt.want {
+				t.Errorf("TopN = %d, want %d", config.TopN, tt.want)
+			}
+		})
+	}
+}
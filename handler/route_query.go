@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"transit-api/normalize"
+)
+
+// route_transit accepts at most this many via stations
+const maxViaStations = 3
+
+// unuseCodes are the route_transit unuse values accepted by /transit
+var unuseCodes = []string{
+	"domestic_flight",
+	"superexpress_train",
+	"sleeper_ultraexpress",
+	"ultraexpress_train",
+	"express_train",
+	"semiexpress_train",
+	"shuttle_bus",
+}
+
+// orderCodes are the route_transit order values accepted by /transit
+var orderCodes = []string{"time_optimized", "total_time", "fare", "transit"}
+
+// avoidUnuse maps the agent's avoid preferences to unuse codes
+// route_transit has no code for rapid trains, so they cannot be avoided
+var avoidUnuse = map[string]string{
+	"flight":          "domestic_flight",
+	"shinkansen":      "superexpress_train",
+	"sleeper":         "sleeper_ultraexpress",
+	"limited_express": "ultraexpress_train",
+	"express":         "express_train",
+	"semi_express":    "semiexpress_train",
+	"shuttle_bus":     "shuttle_bus",
+}
+
+// preferOrder maps the agent's prefer preference to an order code
+var preferOrder = map[string]string{
+	"fastest":          "total_time",
+	"fewest_transfers": "transit",
+	"cheapest":         "fare",
+}
+
+// routeQuery holds the route search parameters besides the two stations
+// Exactly one of StartTime (depart at) and GoalTime (arrive by) is set
+type routeQuery struct {
+	StartTime string
+	GoalTime  string
+	Via       []string
+	Unuse     []string
+	Order     string
+}
+
+// routeQueryFromRequest reads start_time or goal_time, via, unuse and order
+// Times are rounded down to the minute for a better cache hit rate,
+// e.g., 09:05:12 and 09:05:45 both cache as 09:05:00
+// It reports false when a parameter is invalid
+func routeQueryFromRequest(r *http.Request) (routeQuery, bool) {
+	q := r.URL.Query()
+	query := routeQuery{
+		StartTime: roundTransitTime(q.Get("start_time")),
+		GoalTime:  roundTransitTime(q.Get("goal_time")),
+		Order:     q.Get("order"),
+	}
+	for via := range strings.SplitSeq(q.Get("via"), ",") {
+		if via = normalize.Query(via); via != "" {
+			query.Via = append(query.Via, via)
+		}
+	}
+	for unuse := range strings.SplitSeq(q.Get("unuse"), ",") {
+		if unuse = strings.TrimSpace(unuse); unuse != "" {
+			query.Unuse = append(query.Unuse, unuse)
+		}
+	}
+	return query, query.valid()
+}
+
+// valid reports whether the query has one time and only known codes
+func (q routeQuery) valid() bool {
+	if (q.StartTime == "") == (q.GoalTime == "") {
+		return false
+	}
+	if len(q.Via) > maxViaStations {
+		return false
+	}
+	if q.Order != "" && !slices.Contains(orderCodes, q.Order) {
+		return false
+	}
+	for _, unuse := range q.Unuse {
+		if !slices.Contains(unuseCodes, unuse) {
+			return false
+		}
+	}
+	return true
+}
+
+// plain reports whether the query is a departure time with no preferences,
+// the only kind the prewarmer replays
+func (q routeQuery) plain() bool {
+	return q.GoalTime == "" && len(q.Via) == 0 && len(q.Unuse) == 0 && q.Order == ""
+}
+
+// key identifies the query in transit cache keys
+// A plain departure is just the time, so existing keys keep their format;
+// e.g. "2024-01-15T09:00:00" or "arrive 2024-01-15T19:00:00;via=品川;order=transit"
+func (q routeQuery) key() string {
+	key := q.StartTime
+	if q.GoalTime != "" {
+		key = "arrive " + q.GoalTime
+	}
+	for i, via := range q.Via {
+		if i == 0 {
+			key += ";via="
+		} else {
+			key += ","
+		}
+		key += normalize.Station(via)
+	}
+	if len(q.Unuse) > 0 {
+		unuse := slices.Clone(q.Unuse)
+		slices.Sort(unuse)
+		key += ";unuse=" + strings.Join(unuse, ",")
+	}
+	if q.Order != "" {
+		key += ";order=" + q.Order
+	}
+	return key
+}
+
+// values returns the /transit query parameters for q
+func (q routeQuery) values() url.Values {
+	values := url.Values{}
+	if q.StartTime != "" {
+		values.Set("start_time", q.StartTime)
+	}
+	if q.GoalTime != "" {
+		values.Set("goal_time", q.GoalTime)
+	}
+	if len(q.Via) > 0 {
+		values.Set("via", strings.Join(q.Via, ","))
+	}
+	if len(q.Unuse) > 0 {
+		values.Set("unuse", strings.Join(q.Unuse, ","))
+	}
+	if q.Order != "" {
+		values.Set("order", q.Order)
+	}
+	return values
+}
+
+// upstreamParams resolves via stations to node IDs and returns the
+// route_transit parameters after start and goal
+func (q routeQuery) upstreamParams() (string, error) {
+	params := url.Values{}
+	if q.StartTime != "" {
+		params.Set("start_time", q.StartTime)
+	}
+	if q.GoalTime != "" {
+		params.Set("goal_time", q.GoalTime)
+	}
+	if len(q.Unuse) > 0 {
+		params.Set("unuse", strings.Join(q.Unuse, "."))
+	}
+	if q.Order != "" {
+		params.Set("order", q.Order)
+	}
+
+	if len(q.Via) > 0 {
+		nodes, err := resolveVia(q.Via)
+		if err != nil {
+			return "", err
+		}
+		encoded, err := json.Marshal(nodes)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode via: %w", err)
+		}
+		params.Set("via", string(encoded))
+	}
+	return params.Encode(), nil
+}
+
+// viaNode is one entry of route_transit's via JSON array
+type viaNode struct {
+	ID string `json:"id"`
+}
+
+// resolveVia resolves via station names to node IDs concurrently
+func resolveVia(names []string) ([]viaNode, error) {
+	var wg sync.WaitGroup
+	nodes := make([]viaNode, len(names))
+	for i, name := range names {
+		wg.Go(func() {
+			channel := make(chan string, 1)
+			resolveNode(stationChoice{Name: name}, channel)
+			nodes[i].ID = <-channel
+		})
+	}
+	wg.Wait()
+
+	for _, node := range nodes {
+		if node.ID == "" {
+			return nil, errNodesNotFound
+		}
+	}
+	return nodes, nil
+}
+
+// roundTransitTime truncates a start_time or goal_time to the minute
+// Unparseable values are passed through for the upstream API to reject
+func roundTransitTime(value string) string {
+	if parsed, err := time.Parse(transitTimeLayout, value); err == nil {
+		return parsed.Truncate(time.Minute).Format(transitTimeLayout)
+	}
+	return value
+}
+
+// agentRouteQuery converts the times and preferences extracted by the model
+// into a routeQuery. Times are in JST; without one, the route departs at now.
+// 終電 (last train) is searched as arriving by 01:00 after the departure day.
+func agentRouteQuery(departure, arrival string, lastTrain bool, via, avoid []string, prefer string, now time.Time) routeQuery {
+	var query routeQuery
+	day := now
+	if parsed, err := time.ParseInLocation(transitTimeLayout, departure, jst); err == nil {
+		query.StartTime = parsed.Truncate(time.Minute).Format(transitTimeLayout)
+		day = parsed
+	}
+	if parsed, err := time.ParseInLocation(transitTimeLayout, arrival, jst); err == nil {
+		query.StartTime = ""
+		query.GoalTime = parsed.Truncate(time.Minute).Format(transitTimeLayout)
+	} else if lastTrain {
+		// After midnight the last train of the night is still ahead today
+		if day.Hour() >= 4 {
+			day = day.AddDate(0, 0, 1)
+		}
+		query.StartTime = ""
+		query.GoalTime = time.Date(day.Year(), day.Month(), day.Day(), 1, 0, 0, 0, jst).Format(transitTimeLayout)
+	}
+	if query.StartTime == "" && query.GoalTime == "" {
+		query.StartTime = now.Truncate(time.Minute).Format(transitTimeLayout)
+	}
+
+	for _, name := range via {
+		if name = normalize.Query(name); name != "" && len(query.Via) < maxViaStations {
+			query.Via = append(query.Via, name)
+		}
+	}
+	for _, avoided := range avoid {
+		if unuse, ok := avoidUnuse[avoided]; ok && !slices.Contains(query.Unuse, unuse) {
+			query.Unuse = append(query.Unuse, unuse)
+		}
+	}
+	query.Order = preferOrder[prefer]
+	return query
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAgentRouteQuery(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 42, 31, 0, jst)
+	tests := []struct {
+		name      string
+		departure string
+		arrival   string
+		lastTrain bool
+		avoid     []string
+		prefer    string
+		want      routeQuery
+	}{
+		{
+			name: "no time departs now",
+			want: routeQuery{StartTime: "2026-10-19T17:42:00"},
+		},
+		{
+			name:      "departure",
+			departure: "2026-10-20T08:00:00",
+			want:      routeQuery{StartTime: "2026-10-20T08:00:00"},
+		},
+		{
+			name:    "arrival wins over departure",
+			arrival: "2026-10-19T19:00:30",
+			want:    routeQuery{GoalTime: "2026-10-19T19:00:00"},
+		},
+		{
+			name:      "last train tonight",
+			lastTrain: true,
+			want:      routeQuery{GoalTime: "2026-10-20T01:00:00"},
+		},
+		{
+			name:      "last train on a given day",
+			departure: "2026-10-24T22:00:00",
+			lastTrain: true,
+			want:      routeQuery{GoalTime: "2026-10-25T01:00:00"},
+		},
+		{
+			name:      "invalid time departs now",
+			departure: "tomorrow morning",
+			want:      routeQuery{StartTime: "2026-10-19T17:42:00"},
+		},
+		{
+			name:   "avoid and prefer",
+			avoid:  []string{"shinkansen", "limited_express", "shinkansen", "teleport"},
+			prefer: "fewest_transfers",
+			want: routeQuery{
+				StartTime: "2026-10-19T17:42:00",
+				Unuse:     []string{"superexpress_train", "ultraexpress_train"},
+				Order:     "transit",
+			},
+		},
+		{
+			name:   "unknown prefer",
+			prefer: "scenic",
+			want:   routeQuery{StartTime: "2026-10-19T17:42:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := agentRouteQuery(tt.departure, tt.arrival, tt.lastTrain, nil, tt.avoid, tt.prefer, now)
+			if got.StartTime != tt.want.StartTime || got.GoalTime != tt.want.GoalTime ||
+				!slices.Equal(got.Unuse, tt.want.Unuse) || got.Order != tt.want.Order {
+				t.Errorf("agentRouteQuery() = %+v, want %+v", got, tt.want)
+			}
+			if !got.valid() {
+				t.Errorf("agentRouteQuery() = %+v is not a valid query", got)
+			}
+		})
+	}
+}
+
+func TestAgentRouteQueryAvoid(t *testing.T) {
+	now := time.Date(2026, 10, 19, 17, 42, 31, 0, jst)
+	want := map[string]string{
+		"flight":          "domestic_flight",
+		"shinkansen":      "superexpress_train",
+		"sleeper":         "sleeper_ultraexpress",
+		"limited_express": "ultraexpress_train",
+		"express":         "express_train",
+		"semi_express":    "semiexpress_train",
+		"shuttle_bus":     "shuttle_bus",
+	}
+	for avoided, unuse := range want {
+		got := agentRouteQuery("", "", false, nil, []string{avoided}, "", now).Unuse
+		if !slices.Equal(got, []string{unuse}) {
+			t.Errorf("avoid %s: unuse = %q, want %q", avoided, got, unuse)
+		}
+		if !slices.Contains(unuseCodes, unuse) {
+			t.Errorf("avoid %s maps to %s, which /transit does not accept", avoided, unuse)
+		}
+	}
+	if len(avoidUnuse) != len(want) {
+		t.Errorf("avoidUnuse has %d options, want %d", len(avoidUnuse), len(want))
+	}
+}
+
+func TestRouteQueryUpstreamParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query routeQuery
+		want  string
+	}{
+		{name: "departure", query: routeQuery{StartTime: "2024-01-15T09:00:00"}, want: "start_time=2024-01-15T09%3A00%3A00"},
+		{name: "arrival", query: routeQuery{GoalTime: "2024-01-15T19:00:00"}, want: "goal_time=2024-01-15T19%3A00%3A00"},
+		{
+			name:  "unuse is dot-joined",
+			query: routeQuery{StartTime: "2024-01-15T09:00:00", Unuse: []string{"superexpress_train", "semiexpress_train", "shuttle_bus"}, Order: "fare"},
+			want:  "order=fare&start_time=2024-01-15T09%3A00%3A00&unuse=superexpress_train.semiexpress_train.shuttle_bus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.query.upstreamParams()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("upstreamParams() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteQueryFromRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantKey   string
+		wantValid bool
+	}{
+		{name: "plain departure keeps the old key", query: "start_time=2024-01-15T09:00:45", wantKey: "2024-01-15T09:00:00", wantValid: true},
+		{name: "arrive by", query: "goal_time=2024-01-15T19:00:00", wantKey: "arrive 2024-01-15T19:00:00", wantValid: true},
+		{name: "via normalized", query: "start_time=2024-01-15T09:00:00&via=品川駅,%20ＪＲ大井町", wantKey: "2024-01-15T09:00:00;via=品川,JR大井町", wantValid: true},
+		{name: "unuse sorted in key", query: "start_time=2024-01-15T09:00:00&unuse=express_train,superexpress_train&order=fare", wantKey: "2024-01-15T09:00:00;unuse=express_train,superexpress_train;order=fare", wantValid: true},
+		{name: "both times", query: "start_time=2024-01-15T09:00:00&goal_time=2024-01-15T10:00:00"},
+		{name: "no time", query: "order=fare"},
+		{name: "unknown unuse", query: "start_time=2024-01-15T09:00:00&unuse=bicycle"},
+		{name: "unknown order", query: "start_time=2024-01-15T09:00:00&order=scenic"},
+		{name: "too many via", query: "start_time=2024-01-15T09:00:00&via=a,b,c,d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, valid := routeQueryFromRequest(httptest.NewRequest("GET", "/transit?"+tt.query, nil))
+			if valid != tt.wantValid {
+				t.Fatalf("valid = %v, want %v", valid, tt.wantValid)
+			}
+			if valid && query.key() != tt.wantKey {
+				t.Errorf("key() = %q, want %q", query.key(), tt.wantKey)
+			}
+		})
+	}
+}
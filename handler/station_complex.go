@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"transit-api/cache"
+	"transit-api/model"
+	"transit-api/normalize"
+)
+
+// Nodes with the same base name within this distance belong to one complex,
+// so same-name stations in different places (大手町 in Tokyo and in Gunma)
+// stay separate
+const complexRadiusMeters = 1000
+
+// prefecturePattern matches a qualifier naming a prefecture (東京都, 北海道,
+// 大阪府, 神奈川県), which tells apart same-name stations rather than operators
+var prefecturePattern = regexp.MustCompile(`^\p{Han}{2,3}[都道府県]$`)
+
+// Station name + operator -> node ID
+// Node IDs never change, so entries live as long as autocomplete's
+var operatorNodeCache = cache.NewLRUCache(5000, 30*24*time.Hour)
+
+// stationChoice selects the node used for one end of a route: a specific
+// node ID, one operator's node within the named complex, or any node
+// Name is kept as typed for upstream lookups and normalized in keys
+type stationChoice struct {
+	Name     string
+	Operator string
+	NodeID   string
+}
+
+// stationChoiceFromRequest reads the station, <param>_operator and <param>_id
+// query parameters for one end of a route
+func stationChoiceFromRequest(r *http.Request, param string) stationChoice {
+	query := r.URL.Query()
+	return stationChoice{
+		Name:     normalize.Query(query.Get(param)),
+		Operator: normalize.Text(query.Get(param + "_operator")),
+		NodeID:   strings.TrimSpace(query.Get(param + "_id")),
+	}
+}
+
+// empty reports whether no station was given
+func (c stationChoice) empty() bool {
+	return c.Name == "" && c.NodeID == ""
+}
+
+// any reports whether any node of the named station may be used
+func (c stationChoice) any() bool {
+	return c.NodeID == "" && c.Operator == ""
+}
+
+// key identifies the choice in transit cache keys
+// e.g. "新宿", "新宿@都営" or "#00004254"
+func (c stationChoice) key() string {
+	switch {
+	case c.NodeID != "":
+		return "#" + c.NodeID
+	case c.Operator != "":
+		return normalize.Station(c.Name) + "@" + c.Operator
+	default:
+		return normalize.Station(c.Name)
+	}
+}
+
+// resolveNode sends the node ID for choice on channel, or "" if nothing matched
+func resolveNode(choice stationChoice, channel chan<- string) {
+	switch {
+	case choice.NodeID != "":
+		channel <- choice.NodeID
+	case choice.Operator != "":
+		channel <- operatorNode(resolveStationName(choice.Name), choice.Operator)
+	default:
+		// Romaji or kana input is mapped to the Japanese name before resolution
+		fetchNodes(resolveStationName(choice.Name), channel)
+	}
+}
+
+// operatorNode finds the member of the station complex named name whose
+// operator contains operator, e.g. "都営" matches the "新宿(都営線)" node
+func operatorNode(name, operator string) string {
+	cacheKey := normalize.Station(name) + "|" + operator
+	if cached, ok := operatorNodeCache.Get(cacheKey); ok {
+		return cached.(string)
+	}
+
+	key := os.Getenv("RAPIDAPI_KEY")
+	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
+	stations, err := fetchAutocompleteStations(name, key, host)
+	if err != nil {
+		log.Printf("Error fetching complex %s: %v", name, err)
+		return ""
+	}
+
+	want := strings.ToLower(operator)
+	for _, group := range groupStations(stationsOnly(stations)) {
+		base, _ := stationBaseName(group.Name)
+		if normalize.Station(base) != normalize.Station(name) {
+			continue
+		}
+		for _, member := range group.Members {
+			if strings.Contains(strings.ToLower(member.Operator), want) {
+				operatorNodeCache.Set(cacheKey, member.ID)
+				return member.ID
+			}
+		}
+	}
+	log.Printf("No %s node found for operator %s", name, operator)
+	return ""
+}
+
+// stationsOnly keeps the autocomplete items whose first type is station
+func stationsOnly(items []model.AutocompleteStation) []model.AutocompleteStation {
+	stations := make([]model.AutocompleteStation, 0, len(items))
+	for _, item := range items {
+		if len(item.Types) > 0 {
+			item.Type = item.Types[0]
+		}
+		if item.Type == "station" {
+			stations = append(stations, item)
+		}
+	}
+	return stations
+}
+
+// stationBaseName splits an upstream name into the station name and the
+// parenthesized qualifier that tells same-name nodes apart,
+// e.g. "新宿(都営線)" -> "新宿", "都営線"
+func stationBaseName(name string) (string, string) {
+	name = normalize.Text(name)
+	if strings.HasSuffix(name, ")") {
+		if i := strings.LastIndex(name, "("); i > 0 {
+			return strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1 : len(name)-1])
+		}
+	}
+	return name, ""
+}
+
+// groupStations collapses station nodes into complexes by base name and
+// distance, in the order each complex first appears
+// A complex is represented by its unqualified node when it has one, so its ID
+// stands for the station as a whole rather than one operator's platform
+func groupStations(stations []model.AutocompleteStation) []model.StationComplex {
+	var complexes []model.StationComplex
+	for _, station := range stations {
+		base, qualifier := stationBaseName(station.Name)
+		member := model.StationMember{ID: station.ID, Name: station.Name, Operator: qualifier}
+		if prefecturePattern.MatchString(qualifier) {
+			member.Operator, member.Prefecture = "", qualifier
+		}
+
+		i := slices.IndexFunc(complexes, func(group model.StationComplex) bool {
+			return group.Name == base && distanceMeters(group.Coord, station.Coord) <= complexRadiusMeters
+		})
+		if i < 0 {
+			complexes = append(complexes, model.StationComplex{
+				ID:      station.ID,
+				Name:    base,
+				Ruby:    station.Ruby,
+				Coord:   station.Coord,
+				Members: []model.StationMember{member},
+			})
+			continue
+		}
+
+		complexes[i].Members = append(complexes[i].Members, member)
+		// The unqualified node's ruby reads the base name alone
+		if qualifier == "" {
+			complexes[i].ID = station.ID
+			complexes[i].Ruby = station.Ruby
+			complexes[i].Coord = station.Coord
+		}
+	}
+
+	// A lone node keeps its full name, since its qualifier may be what tells
+	// it apart from a same-name station elsewhere ("大手町(群馬県)")
+	for i, group := range complexes {
+		if len(group.Members) == 1 {
+			complexes[i].Name = group.Members[0].Name
+		}
+	}
+	return complexes
+}
+
+// distanceMeters returns the great-circle distance between two coordinates
+func distanceMeters(a, b model.Coordinate) float64 {
+	const earthRadiusMeters = 6371000
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
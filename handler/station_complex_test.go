@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	"transit-api/model"
+)
+
+func TestGroupStations(t *testing.T) {
+	shinjuku := model.Coordinate{Lat: 35.6896, Lon: 139.7006}
+	otemachi := model.Coordinate{Lat: 35.6847, Lon: 139.7664}
+	gunma := model.Coordinate{Lat: 36.3895, Lon: 139.0634}
+	items := []model.AutocompleteStation{
+		{ID: "00004254", Name: "新宿(都営線)", Ruby: "しんじゅく(とえいせん)", Types: []string{"station"}, Coord: shinjuku},
+		{ID: "00005036", Name: "大手町(東京都)", Ruby: "おおてまち", Types: []string{"station"}, Coord: otemachi},
+		{ID: "00004253", Name: "新宿", Ruby: "しんじゅく", Types: []string{"station"}, Coord: model.Coordinate{Lat: 35.6909, Lon: 139.7003}},
+		{ID: "00010001", Name: "新宿駅西口", Types: []string{"bus_stop"}, Coord: shinjuku},
+		{ID: "00004255", Name: "新宿(東京メトロ)", Ruby: "しんじゅく(とうきょうめとろ)", Types: []string{"station", "bus_stop"}, Coord: model.Coordinate{Lat: 35.6913, Lon: 139.7021}},
+		{ID: "00030001", Name: "大手町(群馬県)", Ruby: "おおてまち", Types: []string{"station"}, Coord: gunma},
+	}
+
+	got := groupStations(stationsOnly(items))
+	want := []model.StationComplex{
+		{
+			// The complex takes the unqualified node's ID, ruby and coordinate
+			ID:    "00004253",
+			Name:  "新宿",
+			Ruby:  "しんじゅく",
+			Coord: model.Coordinate{Lat: 35.6909, Lon: 139.7003},
+			Members: []model.StationMember{
+				{ID: "00004254", Name: "新宿(都営線)", Operator: "都営線"},
+				{ID: "00004253", Name: "新宿"},
+				{ID: "00004255", Name: "新宿(東京メトロ)", Operator: "東京メトロ"},
+			},
+		},
+		{
+			// Same-name stations far apart stay separate and keep their full
+			// names; a prefecture qualifier is not an operator
+			ID:      "00005036",
+			Name:    "大手町(東京都)",
+			Ruby:    "おおてまち",
+			Coord:   otemachi,
+			Members: []model.StationMember{{ID: "00005036", Name: "大手町(東京都)", Prefecture: "東京都"}},
+		},
+		{
+			ID:      "00030001",
+			Name:    "大手町(群馬県)",
+			Ruby:    "おおてまち",
+			Coord:   gunma,
+			Members: []model.StationMember{{ID: "00030001", Name: "大手町(群馬県)", Prefecture: "群馬県"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupStations() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestStationBaseName(t *testing.T) {
+	tests := []struct {
+		name          string
+		wantBase      string
+		wantQualifier string
+	}{
+		{name: "新宿(都営線)", wantBase: "新宿", wantQualifier: "都営線"},
+		{name: "新宿（東京メトロ）", wantBase: "新宿", wantQualifier: "東京メトロ"},
+		{name: "新宿", wantBase: "新宿"},
+		{name: "(仮称)", wantBase: "(仮称)"},
+	}
+	for _, tt := range tests {
+		base, qualifier := stationBaseName(tt.name)
+		if base != tt.wantBase || qualifier != tt.wantQualifier {
+			t.Errorf("stationBaseName(%q) = %q, %q, want %q, %q", tt.name, base, qualifier, tt.wantBase, tt.wantQualifier)
+		}
+	}
+}
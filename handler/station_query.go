@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"transit-api/cache"
+	"transit-api/kana"
+	"transit-api/model"
+)
+
+// Romaji/kana query -> canonical Japanese station name
+// Readings of stations don't change, so entries live as long as autocomplete's
+var canonicalNameCache = cache.NewLRUCache(5000, 30*24*time.Hour)
+
+// Each reading looked up is a rate-limited upstream call, so only the likeliest
+// few are tried
+const maxReadingLookups = 3
+
+// stationReadings returns the hiragana readings of romaji or kana input,
+// most likely first. Romaji may stand for several readings (kana.RomajiReadings).
+// It reports false for input containing kanji or text that is not romaji,
+// such as "JR", which is passed upstream unchanged
+func stationReadings(word string) ([]string, bool) {
+	compact := strings.NewReplacer(" ", "", "　", "", "-", "").Replace(strings.TrimSpace(word))
+	if kana.IsKana(compact) {
+		return []string{kana.KatakanaToHiragana(compact)}, true
+	}
+	if kana.IsRomaji(compact) {
+		readings := kana.RomajiReadings(compact)
+		if kana.IsKana(readings[0]) {
+			return readings, true
+		}
+	}
+	return nil, false
+}
+
+// fetchStationsByReading looks up to maxReadingLookups readings up upstream in
+// order and returns the stations whose ruby matches the first reading that
+// finds any, with that reading
+func fetchStationsByReading(readings []string, key, host string) ([]model.AutocompleteStation, string, error) {
+	for _, reading := range readings[:min(len(readings), maxReadingLookups)] {
+		stations, err := fetchAutocompleteStations(reading, key, host)
+		if err != nil {
+			return nil, "", err
+		}
+		if matched := filterByReading(stations, reading); len(matched) > 0 {
+			return matched, reading, nil
+		}
+	}
+	return nil, readings[0], nil
+}
+
+// filterByReading keeps the stations whose ruby starts with reading,
+// ignoring script and long vowels
+func filterByReading(stations []model.AutocompleteStation, reading string) []model.AutocompleteStation {
+	folded := kana.FoldLongVowels(reading)
+	matched := make([]model.AutocompleteStation, 0, len(stations))
+	for _, station := range stations {
+		if strings.HasPrefix(kana.FoldLongVowels(station.Ruby), folded) {
+			matched = append(matched, station)
+		}
+	}
+	return matched
+}
+
+// resolveStationName maps romaji or kana input to the canonical Japanese
+// station name by matching autocomplete ruby readings. Other input, and input
+// with no matching station, is returned unchanged.
+func resolveStationName(word string) string {
+	readings, ok := stationReadings(word)
+	if !ok {
+		return word
+	}
+	if cached, ok := canonicalNameCache.Get(readings[0]); ok {
+		return cached.(string)
+	}
+
+	key := os.Getenv("RAPIDAPI_KEY")
+	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
+	stations, reading, err := fetchStationsByReading(readings, key, host)
+	if err != nil {
+		log.Printf("Error resolving station reading %s: %v", readings[0], err)
+		return word
+	}
+
+	// Prefer an exact reading match, then the first prefix match
+	folded := kana.FoldLongVowels(reading)
+	var best *model.AutocompleteStation
+	for i, station := range stations {
+		if kana.FoldLongVowels(station.Ruby) == folded {
+			best = &station
+			break
+		}
+		if i == 0 {
+			best = &station
+		}
+	}
+	if best == nil {
+		log.Printf("No station matches reading %s for %s", reading, word)
+		return word
+	}
+
+	canonicalNameCache.Set(readings[0], best.Name)
+	return best.Name
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"transit-api/locale"
+	"transit-api/model"
+	"transit-api/normalize"
+)
+
+// Number of real stations offered back to the model when a name misses
+const maxStationCandidates = 5
+
+// stationCheck is the result of verifying a station name against the index
+type stationCheck struct {
+	Name       string
+	NodeID     string
+	Exact      bool
+	Candidates []string
+}
+
+// verifyStation looks name up through autocomplete. An item whose name (or
+// base name, for a complex member) equals the normalized input is an exact
+// match; otherwise the closest real stations are returned as candidates.
+func verifyStation(name string) (stationCheck, error) {
+	query := normalize.Query(name)
+	word := normalize.Station(query)
+	if word == "" {
+		return stationCheck{}, nil
+	}
+
+	items, err := stationIndex(query)
+	if err != nil {
+		return stationCheck{}, err
+	}
+	for _, item := range items {
+		base, _ := stationBaseName(item.Name)
+		if normalize.Station(item.Name) == word || normalize.Station(base) == word {
+			return stationCheck{Name: item.Name, NodeID: item.ID, Exact: true}, nil
+		}
+	}
+
+	// Invented names ("東京タワー駅") match no prefix, so fall back to
+	// stations sharing their first two characters
+	if runes := []rune(word); len(items) == 0 && len(runes) > 2 {
+		if items, err = stationIndex(string(runes[:2])); err != nil {
+			return stationCheck{}, err
+		}
+	}
+
+	check := stationCheck{}
+	for _, item := range items {
+		if len(check.Candidates) == maxStationCandidates {
+			break
+		}
+		check.Candidates = append(check.Candidates, item.Name)
+	}
+	return check, nil
+}
+
+// stationIndex returns the Japanese autocomplete items for word
+func stationIndex(word string) ([]model.FilteredStation, error) {
+	key := os.Getenv("RAPIDAPI_KEY")
+	host := os.Getenv("RAPIDAPI_TRANSPORT_HOST")
+	body, _, _, err := autocompleteResults(word, locale.Japanese.String(), "", key, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var response model.FilteredAutocompleteResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse autocomplete response: %w", err)
+	}
+	return response.Items, nil
+}
+
+// missFeedback describes a failed check so the model can correct itself
+func missFeedback(field, name string, check stationCheck) string {
+	if len(check.Candidates) == 0 {
+		return fmt.Sprintf("%s %q is not a station. Choose the nearest real station instead.", field, name)
+	}
+	return fmt.Sprintf("%s %q is not a station. The closest real stations are: %s.", field, name, strings.Join(check.Candidates, ", "))
+}
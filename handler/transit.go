@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,17 +11,33 @@ import (
 	"sync"
 	"time"
 	"transit-api/cache"
+	"transit-api/locale"
 	"transit-api/model"
 	"transit-api/utils"
-	
+
 	"github.com/jtclarkjr/router-go/middleware"
 )
 
 // Response cache with 5 minute TTL, max 1000 entries
-// Cache key format: "start|goal|time_rounded_to_minute|lang"
+// Cache key format: "start|goal|time_rounded_to_minute[;via=..][;unuse=..][;order=..]|locale|names|romaji_style"
+// Arrive-by searches prefix the time with "arrive "
+// Stations are normalized first, so "新宿駅" and "新宿" share an entry;
+// operator or node choices are part of the station ("新宿@都営", "#00004254")
 // Timestamps are rounded to the nearest minute to improve cache hit rate
 var responseCache = cache.NewLRUCache(1000, 5*time.Minute)
 
+// transitTimeLayout is the start_time format accepted by /transit and route_transit
+const transitTimeLayout = "2006-01-02T15:04:05"
+
+// Errors from fetchTransit that map to specific user-facing messages
+var (
+	errNodesNotFound     = errors.New("failed to fetch nodes")
+	errTranslationFailed = errors.New("failed to translate values")
+)
+
+// Single flight so concurrent misses on the same cache key share one upstream call
+var transitSF = middleware.NewSingleFlight()
+
 // Transit handles transit route requests
 // @Summary Get transit routes between stations
 // @Description Get transit route options between two stations with optional language translation
@@ -29,9 +46,22 @@ var responseCache = cache.NewLRUCache(1000, 5*time.Minute)
 // @Produce json
 // @Param start query string true "Starting station name" example("東京駅")
 // @Param goal query string true "Destination station name" example("新宿駅")
-// @Param start_time query string true "Start time in format YYYY-MM-DDTHH:MM:SS" example("2024-01-15T09:00:00")
-// @Param lang query string false "Language for response (en for English/Romaji)" example("en")
+// @Param start_operator query string false "Use the start complex's node for this operator instead of any node" example("都営")
+// @Param goal_operator query string false "Use the goal complex's node for this operator instead of any node" example("JR")
+// @Param start_id query string false "Starting node ID from autocomplete members; replaces start" example("00004254")
+// @Param goal_id query string false "Destination node ID from autocomplete members; replaces goal" example("00004254")
+// @Param start_time query string false "Departure time in format YYYY-MM-DDTHH:MM:SS; required unless goal_time is set" example("2024-01-15T09:00:00")
+// @Param goal_time query string false "Arrive-by time in format YYYY-MM-DDTHH:MM:SS, instead of start_time" example("2024-01-15T19:00:00")
+// @Param via query string false "Up to 3 comma-separated stations to pass through" example("品川")
+// @Param unuse query string false "Comma-separated transport to avoid (domestic_flight, superexpress_train, sleeper_ultraexpress, ultraexpress_train, express_train, semiexpress_train, shuttle_bus)" example("superexpress_train")
+// @Param order query string false "Route ordering (time_optimized, total_time, fare, transit)" example("transit")
+// @Param lang query string false "Language for response (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language" example("en")
+// @Param Accept-Language header string false "Preferred languages with quality values" example("en-US,en;q=0.9")
+// @Param names query string false "Set to bilingual to keep Japanese names and add name_en and name_ruby" example("bilingual")
+// @Param romaji_style query string false "Romanization style for Romaji names (hepburn, passport, passport-oh, kunrei, wapuro)" example("hepburn")
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} model.TransitResponse "Successful response with transit routes"
+// @Success 304 {string} string "Not modified - If-None-Match matched the current ETag"
 // @Failure 400 {string} string "Bad request - missing or invalid parameters"
 // @Failure 500 {string} string "Internal server error"
 // @Router /transit [get]
@@ -42,128 +72,184 @@ func Transit() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// startTime := time.Now()
 
-		startStation := r.URL.Query().Get("start")
-		endStation := r.URL.Query().Get("goal")
-		startTimeStr := r.URL.Query().Get("start_time")
-		lang := r.URL.Query().Get("lang")
+		start := stationChoiceFromRequest(r, "start")
+		goal := stationChoiceFromRequest(r, "goal")
+		loc := locale.FromRequest(r)
+		lang := loc.String()
+		bilingual := r.URL.Query().Get("names") == "bilingual"
 
-		// Round timestamp to nearest minute for better cache hit rate
-		// e.g., 09:05:12 and 09:05:45 both cache as 09:05:00
-		roundedTime := startTimeStr
-		if parsedTime, err := time.Parse("2006-01-02T15:04:05", startTimeStr); err == nil {
-			roundedTime = parsedTime.Truncate(time.Minute).Format("2006-01-02T15:04:05")
+		query := r.URL.Query()
+		if start.empty() || goal.empty() || (query.Get("start_time") == "" && query.Get("goal_time") == "") {
+			writeError(w, loc, http.StatusBadRequest, locale.MsgMissingParameter, nil)
+			return
 		}
-
-		// Check response cache first
-		cacheKey := fmt.Sprintf("%s|%s|%s|%s", startStation, endStation, roundedTime, lang)
-		if cached, ok := responseCache.Get(cacheKey); ok {
-			log.Printf("[CACHE HIT] Transit: key=%s", cacheKey)
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Cache", "HIT")
-			_, err := w.Write(cached.([]byte))
-			if err != nil {
-				log.Printf("Error writing cached response: %v", err)
-			}
+		routes, ok := routeQueryFromRequest(r)
+		if !ok {
+			writeError(w, loc, http.StatusBadRequest, locale.MsgInvalidParameter, nil)
+			return
+		}
+		style, ok := romajiStyleFromRequest(r)
+		if !ok {
+			writeError(w, loc, http.StatusBadRequest, locale.MsgInvalidParameter, nil)
 			return
 		}
+		options := utils.TranslateOptions{Lang: lang, Bilingual: bilingual, Style: style}
+
+		// Record demand by time of day so the prewarmer can refresh tomorrow's rush
+		// The prewarmer replays plain departures between station names, so only those are recorded
+		if parsedTime, err := time.Parse(transitTimeLayout, routes.StartTime); err == nil && routes.plain() && start.any() && goal.any() {
+			transitDemand.Add(transitDemandKey(start.Name, goal.Name, parsedTime.Format("15:04"), lang))
+		}
 
-		log.Printf("[CACHE MISS] Transit: key=%s, calling API...", cacheKey)
+		result, expiresAt, cacheStatus, err := transitRoutes(start, goal, routes, options, key, host)
+		if err != nil {
+			writeError(w, loc, http.StatusInternalServerError, transitErrorMessage(err), err)
+			return
+		}
+		writeCachedJSON(w, r, loc, result, expiresAt, cacheStatus)
+	}
+}
 
-		var wg sync.WaitGroup
-		startChan := make(chan string, 1)
-		endChan := make(chan string, 1)
+// transitRoutes returns the cached routes between two stations, fetching them
+// on a miss, along with the entry's expiry and the X-Cache status
+func transitRoutes(start, goal stationChoice, routes routeQuery, options utils.TranslateOptions, key, host string) ([]byte, time.Time, string, error) {
+	// Check response cache first
+	cacheKey := transitCacheKey(start, goal, routes, options)
+	if cached, expiresAt, ok := responseCache.GetWithExpiry(cacheKey); ok {
+		log.Printf("[CACHE HIT] Transit: key=%s", cacheKey)
+		return cached.([]byte), expiresAt, "HIT", nil
+	}
 
-		wg.Go(func() {
-			fetchNodes(startStation, startChan)
-		})
-		wg.Go(func() {
-			fetchNodes(endStation, endChan)
-		})
-		wg.Wait()
+	// The rounded times are sent upstream so every caller sharing the key gets the same answer
+	log.Printf("[CACHE MISS] Transit: key=%s, calling API...", cacheKey)
+	result, err := refreshTransit(cacheKey, start, goal, routes, options, key, host)
+	if err != nil {
+		return nil, time.Time{}, "", err
+	}
 
-		startNode := <-startChan
-		endNode := <-endChan
-		close(startChan)
-		close(endChan)
+	_, expiresAt, _ := responseCache.Peek(cacheKey)
+	return result, expiresAt, "MISS", nil
+}
 
-		if startNode == "" || endNode == "" {
-			http.Error(w, "Failed to fetch nodes", http.StatusInternalServerError)
-			return
+// refreshTransit fetches the routes for cacheKey and caches them
+// Single flight shares one upstream call between concurrent misses, and only
+// the caller that made it stores the result
+func refreshTransit(cacheKey string, start, goal stationChoice, routes routeQuery, options utils.TranslateOptions, key, host string) ([]byte, error) {
+	return transitSF.Do(cacheKey, func() ([]byte, error) {
+		result, err := fetchTransit(start, goal, routes, options, key, host)
+		if err != nil {
+			return nil, err
 		}
+		responseCache.Set(cacheKey, result)
+		return result, nil
+	})
+}
+
+// transitErrorMessage picks the user-facing message for a fetchTransit error
+func transitErrorMessage(err error) locale.MessageID {
+	switch {
+	case errors.Is(err, errNodesNotFound):
+		return locale.MsgStationNotFound
+	case errors.Is(err, errTranslationFailed):
+		return locale.MsgTranslationFailed
+	default:
+		return locale.MsgUpstreamFailed
+	}
+}
+
+// transitCacheKey builds the transit cache key ("start|goal|time|locale|names|romaji_style")
+func transitCacheKey(start, goal stationChoice, routes routeQuery, options utils.TranslateOptions) string {
+	names := ""
+	if options.Bilingual {
+		names = "bilingual"
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", start.key(), goal.key(), routes.key(), options.Lang, names, options.Style)
+}
 
-		url := fmt.Sprintf(
-			"https://%s/route_transit?start=%s&goal=%s&start_time=%s&limit=5",
-			host,
-			startNode,
-			endNode,
-			startTimeStr,
-		)
+// fetchTransit resolves both stations and fetches routes between them
+func fetchTransit(start, goal stationChoice, routes routeQuery, options utils.TranslateOptions, key, host string) ([]byte, error) {
+	var wg sync.WaitGroup
+	startChan := make(chan string, 1)
+	endChan := make(chan string, 1)
+
+	wg.Go(func() {
+		resolveNode(start, startChan)
+	})
+	wg.Go(func() {
+		resolveNode(goal, endChan)
+	})
+	wg.Wait()
+
+	startNode := <-startChan
+	endNode := <-endChan
+	close(startChan)
+	close(endChan)
+
+	if startNode == "" || endNode == "" {
+		return nil, errNodesNotFound
+	}
+
+	params, err := routes.upstreamParams()
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf(
+		"https://%s/route_transit?start=%s&goal=%s&%s&limit=5",
+		host,
+		startNode,
+		endNode,
+		params,
+	)
+
+	log.Printf("[API CALL] Transit: start=%s, goal=%s", start.key(), goal.key())
 
-	log.Printf("[API CALL] Transit: start=%s, goal=%s", startStation, endStation)
-	
 	// Rate limit external API call
 	middleware.SharedAPIRateLimiter.Wait()
-	
+
 	request, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			http.Error(w, "Failed to create request", http.StatusInternalServerError)
-			return
-		}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
 
 	request.Header.Add("X-RapidAPI-Key", key)
 	request.Header.Add("X-RapidAPI-Host", host)
 
 	response, err := middleware.SharedHTTPClient.Do(request)
-		if err != nil {
-			http.Error(w, "Failed to fetch data", http.StatusInternalServerError)
-			return
-		}
-		defer func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				log.Printf("Error closing response body: %v", err)
-				http.Error(w, "Failed to close response body", http.StatusInternalServerError)
-				return
-			}
-		}(response.Body)
-
-		body, err := io.ReadAll(response.Body)
-		if err != nil {
-			http.Error(w, "Failed to read response", http.StatusInternalServerError)
-			return
-		}
-
-		var responseData model.TransitResponse
-		if err := json.Unmarshal(body, &responseData); err != nil {
-			http.Error(w, "Failed to parse JSON response", http.StatusInternalServerError)
-			return
-		}
-
-		// Translate values to romaji if lang=en
-		if lang == "en" {
-			if err := utils.TranslateTypedTransitResponse(&responseData); err != nil {
-				log.Printf("Error translating values: %v", err)
-				http.Error(w, "Failed to translate values", http.StatusInternalServerError)
-				return
-			}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			log.Printf("Error closing response body: %v", err)
 		}
+	}(response.Body)
 
-	translatedBody, err := json.Marshal(responseData)
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		http.Error(w, "Failed to encode JSON response", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Cache the response
-	responseCache.Set(cacheKey, translatedBody)
-
-	// println(string(translatedBody))
+	var responseData model.TransitResponse
+	if err := json.Unmarshal(body, &responseData); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
-	_, err = w.Write(translatedBody)
-		if err != nil {
-			return
+	// Translate names for supported languages, or add English names
+	// alongside the Japanese names if names=bilingual
+	if _, ok := utils.TranslatorFor(options.Lang); ok || options.Bilingual {
+		if err := utils.TranslateTypedTransitResponse(&responseData, options); err != nil {
+			log.Printf("Error translating values: %v", err)
+			return nil, fmt.Errorf("%w: %w", errTranslationFailed, err)
 		}
 	}
+
+	// Add localized labels for enumerated codes in every locale
+	utils.LabelEnums(&responseData, locale.Locale(options.Lang))
+
+	translatedBody, err := json.Marshal(responseData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JSON response: %w", err)
+	}
+	return translatedBody, nil
 }
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"transit-api/llm"
+	"transit-api/locale"
+	"transit-api/model"
+	"transit-api/normalize"
+	"transit-api/utils"
+)
+
+// Server-Sent Events emitted by /transit-agent/stream, in order
+const (
+	eventUnderstanding = "understanding"
+	eventCandidates    = "candidates"
+	eventStations      = "stations"
+	eventRoutes        = "routes"
+	eventExplanation   = "explanation"
+	eventDone          = "done"
+	eventError         = "error"
+)
+
+// deltaEvent carries a piece of model output
+// Understanding deltas are numbered by attempt from 1; each retry starts a
+// new answer that replaces the previous attempt's text
+type deltaEvent struct {
+	Attempt int    `json:"attempt,omitempty"`
+	Delta   string `json:"delta"`
+}
+
+// candidatesEvent lists the closest real stations for a name that missed
+type candidatesEvent struct {
+	Field      string   `json:"field"`
+	Name       string   `json:"name"`
+	Candidates []string `json:"candidates"`
+}
+
+// errorEvent ends a stream that failed, with the kind of failure
+type errorEvent struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// errRoutesFailed marks a failed route lookup after the stations were found
+var errRoutesFailed = errors.New("route lookup failed")
+
+// sseWriter writes Server-Sent Events, flushing after each one
+type sseWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+// newSSEWriter sets the event stream headers
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return &sseWriter{w: w, controller: http.NewResponseController(w)}
+}
+
+// send writes one event with data encoded as JSON
+func (s *sseWriter) send(event string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", event, err)
+		return
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, encoded); err != nil {
+		log.Printf("Error writing %s event: %v", event, err)
+		return
+	}
+	// Middleware that can't flush only delays the events
+	_ = s.controller.Flush()
+}
+
+// TransitAgentStream streams the transit agent's progress as Server-Sent Events
+// @Summary Stream a trip plan as Server-Sent Events
+// @Description Emits understanding (model output deltas, numbered by attempt), candidates (closest real stations for a miss), stations (verified TransitAgentResponse), routes (TransitResponse), explanation (deltas) and done events; failures end the stream with an error event
+// @Tags transit-agent
+// @Accept json
+// @Produce text/event-stream
+// @Param request body TransitAgentRequest true "Transit agent request with location prompt"
+// @Param lang query string false "Language for routes and explanation (ja, en, zh-Hans, zh-Hant or ko); overrides Accept-Language" example("en")
+// @Param Accept-Language header string false "Preferred languages with quality values" example("en-US,en;q=0.9")
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} AgentError "Bad request - missing or invalid parameters, or a prompt too long or rejected by the input guard"
+// @Failure 404 {object} AgentError "Session not found or expired"
+// @Failure 429 {object} AgentError "The daily token budget is used up"
+// @Failure 500 {object} AgentError "Internal server error"
+// @Failure 502 {object} AgentError "The LLM failed while moderating the prompt"
+// @Failure 504 {object} AgentError "The LLM timed out while moderating the prompt"
+// @Router /transit-agent/stream [post]
+func TransitAgentStream(w http.ResponseWriter, r *http.Request) {
+	loc := locale.FromRequest(r)
+	setLocaleHeaders(w, loc)
+	if agentLLM == nil {
+		log.Printf("Transit agent stream: LLM not configured")
+		writeAgentError(w, http.StatusInternalServerError, requestFailure(loc, locale.MsgInternalError))
+		return
+	}
+
+	var req TransitAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAgentError(w, http.StatusBadRequest, requestFailure(loc, locale.MsgInvalidParameter))
+		return
+	}
+	if req.Prompt == "" {
+		writeAgentError(w, http.StatusBadRequest, requestFailure(loc, locale.MsgMissingParameter))
+		return
+	}
+
+	session, err := loadSession(req.SessionID)
+	if err != nil {
+		writeAgentError(w, http.StatusNotFound, requestFailure(loc, locale.MsgSessionNotFound))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), transitPlanTimeout)
+	defer cancel()
+
+	// Rejected prompts fail before the stream starts
+	client := meter(agentLLM, callerKey(r))
+	record := auditRecord{Endpoint: "/transit-agent/stream", Caller: callerKey(r), Session: req.SessionID, Prompt: req.Prompt}
+	prompt, err := guardPrompt(ctx, client, req.Prompt)
+	if err != nil {
+		log.Printf("Transit agent stream rejected: %v", redact(err.Error()))
+		status, failure := localizedAgentFailure(loc, err)
+		record.Error = failure.Error
+		audit(record)
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", retryAfter(time.Now()))
+		}
+		writeAgentError(w, status, failure)
+		return
+	}
+
+	stream := newSSEWriter(w)
+	explanation, err := streamTransitAgent(ctx, stream, client, session, prompt, loc, time.Now().In(jst))
+	record.Session, record.Output = session.ID, explanation
+	if err != nil {
+		log.Printf("Transit agent stream failed: %v", redact(err.Error()))
+		event := streamError(loc, err)
+		record.Error = event.Error
+		audit(record)
+		stream.send(eventError, event)
+		return
+	}
+	audit(record)
+	stream.send(eventDone, struct{}{})
+}
+
+// streamTransitAgent runs the station agent, fetches routes and explains them,
+// sending an event for each step, and returns the explanation
+// The stations are recorded in session.
+func streamTransitAgent(ctx context.Context, stream *sseWriter, client llm.Client, session *agentSession, prompt string, loc locale.Locale, now time.Time) (string, error) {
+	events := &agentEvents{
+		understanding: func(attempt int, delta string) {
+			stream.send(eventUnderstanding, deltaEvent{Attempt: attempt, Delta: delta})
+		},
+		candidates: func(field, name string, candidates []string) {
+			stream.send(eventCandidates, candidatesEvent{Field: field, Name: name, Candidates: candidates})
+		},
+	}
+	stations, err := suggestStations(ctx, client, session.prompt(now), session.History, prompt, now, events)
+	if err != nil {
+		return "", err
+	}
+	session.record(prompt, stations)
+	stream.send(eventStations, stations)
+
+	start := stationChoice{Name: normalize.Query(stations.StartStation)}
+	goal := stationChoice{Name: normalize.Query(stations.EndStation)}
+	query := routeQuery{
+		StartTime: stations.StartTime,
+		GoalTime:  stations.GoalTime,
+		Via:       stations.Via,
+		Unuse:     stations.Unuse,
+		Order:     stations.Order,
+	}
+	options := utils.TranslateOptions{Lang: loc.String()}
+	body, _, _, err := transitRoutes(start, goal, query, options, os.Getenv("RAPIDAPI_KEY"), os.Getenv("RAPIDAPI_TRANSIT_HOST"))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errRoutesFailed, err)
+	}
+	var routes model.TransitResponse
+	if err := json.Unmarshal(body, &routes); err != nil {
+		return "", fmt.Errorf("failed to parse routes: %w", err)
+	}
+	if len(routes.Items) == 0 {
+		return "", errNoPlannedRoute
+	}
+	stream.send(eventRoutes, routes)
+
+	return explainRoutes(ctx, client, prompt, routes, loc, func(delta string) {
+		stream.send(eventExplanation, deltaEvent{Delta: delta})
+	})
+}
+
+// explainRoutes asks the model to recommend one of routes for prompt,
+// passing the explanation to onDelta as it is generated
+func explainRoutes(ctx context.Context, client llm.Client, prompt string, routes model.TransitResponse, loc locale.Locale, onDelta func(string)) (string, error) {
+	summaries, err := json.Marshal(summarizeRoutes(routes))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode routes: %w", err)
+	}
+
+	systemPrompt := fmt.Sprintf("You explain Japanese train routes to travellers. In 1-3 sentences, in the language with code %q, recommend the best route for the user's request and say why.", loc)
+	response, err := llm.CompleteStream(ctx, client, llm.Request{
+		Messages: []llm.Message{
+			llm.System(systemPrompt),
+			llm.User(fmt.Sprintf("Request: %s\nRoutes: %s", prompt, summaries)),
+		},
+	}, onDelta)
+	if err != nil {
+		return "", err
+	}
+	return response.Message.Content, nil
+}
+
+// streamError builds the error event for a failed stream
+// Agent failures use the kinds of /transit-agent and keep the model's reason;
+// route failures are kinded by their message ID
+func streamError(loc locale.Locale, err error) errorEvent {
+	var id locale.MessageID
+	switch {
+	case errors.Is(err, errNoPlannedRoute):
+		id = locale.MsgRouteNotFound
+	case errors.Is(err, errRoutesFailed):
+		id = transitErrorMessage(err)
+	default:
+		_, failure := localizedAgentFailure(loc, err)
+		return errorEvent{Error: failure.Error, Message: failure.Message}
+	}
+	return errorEvent{Error: string(id), Message: locale.Message(loc, id)}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtclarkjr/router-go/middleware"
+)
+
+// Concurrent misses on one cache key share a single route_transit call
+func TestTransitSingleFlight(t *testing.T) {
+	requests := stubUpstream(t, `{"items":[]}`)
+	t.Setenv("RAPIDAPI_TRANSIT_HOST", "upstream.test")
+	t.Cleanup(responseCache.Clear)
+
+	// Hold the upstream response until every request has missed the cache
+	release := make(chan struct{})
+	transport := middleware.SharedHTTPClient.Transport
+	middleware.SharedHTTPClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		<-release
+		return transport.RoundTrip(r)
+	})
+	t.Cleanup(func() { middleware.SharedHTTPClient.Transport = transport })
+
+	handler := Transit()
+	const callers = 8
+	codes := make([]int, callers)
+	bodies := make([]string, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Go(func() {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/transit?start_id=00006668&goal_id=00004254&start_time=2024-01-15T09:00:00", nil))
+			codes[i] = w.Code
+			bodies[i] = w.Body.String()
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	var calls int
+	for _, request := range *requests {
+		if strings.HasPrefix(request, "/route_transit?") {
+			calls++
+		}
+	}
+	if calls != 1 {
+		t.Errorf("route_transit calls = %d, want 1: %q", calls, *requests)
+	}
+	for i := range callers {
+		if codes[i] != http.StatusOK || bodies[i] != bodies[0] {
+			t.Errorf("caller %d: status %d, body %q; want 200 and %q", i, codes[i], bodies[i], bodies[0])
+		}
+	}
+}
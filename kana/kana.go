@@ -0,0 +1,344 @@
+package kana
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// romajiTable maps romaji syllables to hiragana
+// Covers Hepburn, Kunrei-shiki and wapuro spellings so input in any of them converts
+var romajiTable = map[string]string{
+	"a": "あ", "i": "い", "u": "う", "e": "え", "o": "お",
+	"ka": "か", "ki": "き", "ku": "く", "ke": "け", "ko": "こ",
+	"kya": "きゃ", "kyu": "きゅ", "kyo": "きょ",
+	"sa": "さ", "shi": "し", "si": "し", "su": "す", "se": "せ", "so": "そ",
+	"sha": "しゃ", "shu": "しゅ", "she": "しぇ", "sho": "しょ",
+	"sya": "しゃ", "syu": "しゅ", "syo": "しょ",
+	"ta": "た", "chi": "ち", "ti": "ち", "tsu": "つ", "tu": "つ", "te": "て", "to": "と",
+	"cha": "ちゃ", "chu": "ちゅ", "che": "ちぇ", "cho": "ちょ",
+	"tya": "ちゃ", "tyu": "ちゅ", "tyo": "ちょ",
+	"na": "な", "ni": "に", "nu": "ぬ", "ne": "ね", "no": "の",
+	"nya": "にゃ", "nyu": "にゅ", "nyo": "にょ",
+	"ha": "は", "hi": "ひ", "fu": "ふ", "hu": "ふ", "he": "へ", "ho": "ほ",
+	"hya": "ひゃ", "hyu": "ひゅ", "hyo": "ひょ",
+	"fa": "ふぁ", "fi": "ふぃ", "fe": "ふぇ", "fo": "ふぉ",
+	"ma": "ま", "mi": "み", "mu": "む", "me": "め", "mo": "も",
+	"mya": "みゃ", "myu": "みゅ", "myo": "みょ",
+	"ya": "や", "yu": "ゆ", "yo": "よ",
+	"ra": "ら", "ri": "り", "ru": "る", "re": "れ", "ro": "ろ",
+	"rya": "りゃ", "ryu": "りゅ", "ryo": "りょ",
+	"wa": "わ", "wi": "うぃ", "we": "うぇ", "wo": "を",
+	"ga": "が", "gi": "ぎ", "gu": "ぐ", "ge": "げ", "go": "ご",
+	"gya": "ぎゃ", "gyu": "ぎゅ", "gyo": "ぎょ",
+	"za": "ざ", "ji": "じ", "zi": "じ", "zu": "ず", "ze": "ぜ", "zo": "ぞ",
+	"ja": "じゃ", "ju": "じゅ", "je": "じぇ", "jo": "じょ",
+	"jya": "じゃ", "jyu": "じゅ", "jyo": "じょ",
+	"zya": "じゃ", "zyu": "じゅ", "zyo": "じょ",
+	"da": "だ", "di": "ぢ", "du": "づ", "de": "で", "do": "ど",
+	"ba": "ば", "bi": "び", "bu": "ぶ", "be": "べ", "bo": "ぼ",
+	"bya": "びゃ", "byu": "びゅ", "byo": "びょ",
+	"pa": "ぱ", "pi": "ぴ", "pu": "ぷ", "pe": "ぺ", "po": "ぽ",
+	"pya": "ぴゃ", "pyu": "ぴゅ", "pyo": "ぴょ",
+	"va": "ゔぁ", "vi": "ゔぃ", "vu": "ゔ", "ve": "ゔぇ", "vo": "ゔぉ",
+	// Hyphens only separate words in romaji, so they are dropped
+	"-": "",
+}
+
+// macronVowels expands long vowels written with macrons or circumflexes
+// A long o is written as its most common kana spelling in place names, おお
+// (Ōsaka, Ōimachi)
+var macronVowels = map[rune]string{
+	'ā': "aa", 'ī': "ii", 'ū': "uu", 'ē': "ee", 'ō': "oo",
+	'â': "aa", 'î': "ii", 'û': "uu", 'ê': "ee", 'ô': "oo",
+}
+
+// maxReadings caps the number of readings RomajiReadings returns
+const maxReadings = 8
+
+// RomajiToHiragana converts romaji to hiragana, spelling the romaji literally
+// Characters that are not part of a romaji syllable are kept unchanged
+func RomajiToHiragana(text string) string {
+	var out strings.Builder
+	for _, segment := range romajiSegments(text) {
+		out.WriteString(segment[0])
+	}
+	return out.String()
+}
+
+// RomajiReadings returns the hiragana readings romaji may stand for, most
+// likely first. Passport-style romaji drops long vowels and the apostrophe of
+// a syllabic n, so "tokyo" may be ときょ or とうきょう and "shinokubo" may be
+// しのくぼ or しんおおくぼ. Readings are ranked by spellingCost, so a contracted
+// o is read long (ちょう) and an o after o as おお, ahead of rarer spellings.
+func RomajiReadings(text string) []string {
+	type candidate struct {
+		reading string
+		cost    int
+	}
+	candidates := []candidate{{}}
+	for _, segment := range romajiSegments(text) {
+		next := make([]candidate, 0, len(candidates)*len(segment))
+		for _, c := range candidates {
+			for _, kana := range segment {
+				next = append(next, candidate{c.reading + kana, c.cost + spellingCost(segment, kana)})
+			}
+		}
+		// Later segments add the same costs to every prefix, so keeping
+		// the cheapest prefixes keeps the cheapest readings
+		slices.SortStableFunc(next, func(a, b candidate) int { return a.cost - b.cost })
+		candidates = next[:min(len(next), maxReadings)]
+	}
+	readings := make([]string, len(candidates))
+	for i, c := range candidates {
+		readings[i] = c.reading
+	}
+	return readings
+}
+
+// spellingCost ranks one spelling of a segment from romajiSegments, 0 for
+// the likeliest. Contracted o syllables are usually long and written う
+// (きょう, ちょう), so their short spelling costs 2. Other long o and u cost 1,
+// like reading n as ん before a vowel, and a long o is written お only after
+// o (大, as in おおさか).
+func spellingCost(segment []string, spelling string) int {
+	literal := segment[0]
+	cost := 0
+	base := literal
+	if strings.HasPrefix(spelling, "ん") && !strings.HasPrefix(literal, "ん") {
+		cost++
+		base = strings.TrimSuffix(strings.TrimSuffix(spelling, "う"), "お")
+		if !slices.Contains(segment, base) {
+			base = spelling
+		}
+	}
+	contracted := strings.HasSuffix(base, "ょ")
+	switch spelling {
+	case base:
+		if contracted && slices.Contains(segment, base+"う") {
+			cost += 2
+		}
+	case base + "う":
+		if !contracted {
+			cost++
+		}
+	case base + "お":
+		if !strings.HasSuffix(base, "お") {
+			cost += 2
+		}
+	}
+	return cost
+}
+
+// romajiSegments splits romaji into kana segments, each listing its literal
+// spelling first and then the spellings the romaji may have dropped: a long
+// vowel, or ん before a vowel written without an apostrophe
+func romajiSegments(text string) [][]string {
+	var expanded strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if long, ok := macronVowels[r]; ok {
+			expanded.WriteString(long)
+		} else {
+			expanded.WriteRune(r)
+		}
+	}
+	src := expanded.String()
+
+	var segments [][]string
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		// n is ん before a consonant, an apostrophe, or the end of input
+		if c == 'n' {
+			next := byte(0)
+			if i+1 < len(src) {
+				next = src[i+1]
+			}
+			if next == '\'' {
+				segments = append(segments, []string{"ん"})
+				i += 2
+				continue
+			}
+			if next == 'n' {
+				segments = append(segments, []string{"ん"})
+				i++
+				// "nn" followed by a vowel or y keeps the second n for the next syllable
+				if i+1 < len(src) && (isVowel(src[i+1]) || src[i+1] == 'y') {
+					continue
+				}
+				i++
+				continue
+			}
+			if !isVowel(next) && next != 'y' {
+				segments = append(segments, []string{"ん"})
+				i++
+				continue
+			}
+		}
+
+		// m before b, p or m is ん in Hepburn (shimbashi)
+		if c == 'm' && i+1 < len(src) && (src[i+1] == 'b' || src[i+1] == 'p' || src[i+1] == 'm') {
+			segments = append(segments, []string{"ん"})
+			i++
+			continue
+		}
+
+		// A doubled consonant (or "tch") is a small tsu
+		if i+1 < len(src) && isConsonant(c) && c != 'n' && (src[i+1] == c || (c == 't' && src[i+1] == 'c')) {
+			segments = append(segments, []string{"っ"})
+			i++
+			continue
+		}
+
+		matched := false
+		for length := 3; length >= 1; length-- {
+			if i+length > len(src) {
+				continue
+			}
+			syllable := src[i : i+length]
+			kana, ok := romajiTable[syllable]
+			if !ok {
+				continue
+			}
+			spellings := []string{kana}
+			// n before a vowel may be ん written without an apostrophe
+			// (shinokubo), though never at the start of a word
+			if c == 'n' && length > 1 && i > 0 {
+				spellings = append(spellings, "ん"+romajiTable[syllable[1:]])
+			}
+			segments = append(segments, withLongVowels(spellings, src, i, i+length))
+			i += length
+			matched = true
+			break
+		}
+		if !matched {
+			r, size := utf8.DecodeRuneInString(src[i:])
+			segments = append(segments, []string{string(r)})
+			i += size
+		}
+	}
+	return segments
+}
+
+// withLongVowels adds the long vowel spellings of a syllable that passport
+// romaji may have dropped: おう and おお after an o (tokyo, osaka) and う after
+// a contracted u (ryu, shu). A vowel already written long (toukyou, oosaka)
+// is left alone. The syllable is src[start:end].
+func withLongVowels(spellings []string, src string, start, end int) []string {
+	if end < len(src) && (src[end] == 'u' || src[end] == 'o') {
+		return spellings
+	}
+	if end-start == 1 && start > 0 && isVowel(src[start-1]) {
+		return spellings
+	}
+	syllable := src[start:end]
+	var suffixes []string
+	switch last := syllable[len(syllable)-1]; {
+	case last == 'o':
+		suffixes = []string{"う", "お"}
+	case strings.HasSuffix(syllable, "yu") || syllable == "shu" || syllable == "chu" || syllable == "ju":
+		suffixes = []string{"う"}
+	default:
+		return spellings
+	}
+	long := slices.Clone(spellings)
+	for _, suffix := range suffixes {
+		for _, spelling := range spellings {
+			long = append(long, spelling+suffix)
+		}
+	}
+	return long
+}
+
+// KatakanaToHiragana converts katakana to hiragana, leaving other runes unchanged
+func KatakanaToHiragana(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 0x60
+		}
+		return r
+	}, text)
+}
+
+// HiraganaToKatakana converts hiragana to katakana, leaving other runes unchanged
+func HiraganaToKatakana(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + 0x60
+		}
+		return r
+	}, text)
+}
+
+// isVowel reports whether c is a romaji vowel
+func isVowel(c byte) bool {
+	return c == 'a' || c == 'i' || c == 'u' || c == 'e' || c == 'o'
+}
+
+// isConsonant reports whether c is an ASCII consonant letter
+func isConsonant(c byte) bool {
+	return c < unicode.MaxASCII && unicode.IsLetter(rune(c)) && !isVowel(c)
+}
+
+// IsKana reports whether text is non-empty and made only of hiragana,
+// katakana and the long vowel mark
+func IsKana(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		isHiragana := r >= 'ぁ' && r <= 'ゖ'
+		isKatakana := r >= 'ァ' && r <= 'ヶ'
+		if !isHiragana && !isKatakana && r != 'ー' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRomaji reports whether text is non-empty and made only of Latin letters
+// (including long vowel marks), spaces, hyphens and apostrophes
+func IsRomaji(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range strings.ToLower(text) {
+		_, isLongVowel := macronVowels[r]
+		isLetter := r >= 'a' && r <= 'z'
+		if !isLetter && !isLongVowel && r != ' ' && r != '-' && r != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+// Kana whose vowel is o or u, used to detect long vowels
+const (
+	oRow = "おこごそぞとどのほぼぽもよょろをぉ"
+	uRow = "うくぐすずつづぬふぶぷむゆゅるぅ"
+)
+
+// FoldLongVowels converts text to hiragana and removes long vowel markers so
+// that readings typed without them still match: とうきょう and ときょ both
+// fold to ときょ, おおさか and おさか both fold to おさか
+func FoldLongVowels(text string) string {
+	runes := []rune(KatakanaToHiragana(text))
+	folded := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		if r == 'ー' {
+			continue
+		}
+		if i > 0 {
+			prev := runes[i-1]
+			if r == 'う' && (strings.ContainsRune(oRow, prev) || strings.ContainsRune(uRow, prev)) {
+				continue
+			}
+			if r == 'お' && strings.ContainsRune(oRow, prev) {
+				continue
+			}
+		}
+		folded = append(folded, r)
+	}
+	return string(folded)
+}
@@ -0,0 +1,152 @@
+package kana
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRomajiToHiragana(t *testing.T) {
+	tests := []struct {
+		romaji string
+		want   string
+	}{
+		{romaji: "shinjuku", want: "しんじゅく"},
+		{romaji: "Shibuya", want: "しぶや"},
+		// Macrons and circumflexes are long vowels; a long o is おお
+		{romaji: "Ōsaka", want: "おおさか"},
+		{romaji: "ôimati", want: "おおいまち"},
+		{romaji: "yūrakuchō", want: "ゆうらくちょお"},
+		// Vowels written out are kept as written
+		{romaji: "toukyou", want: "とうきょう"},
+		{romaji: "oosaka", want: "おおさか"},
+		// Syllabic n before a vowel or y needs an apostrophe
+		{romaji: "shin'okubo", want: "しんおくぼ"},
+		{romaji: "konnichi", want: "こんにち"},
+		{romaji: "kan'yo", want: "かんよ"},
+		{romaji: "kanda", want: "かんだ"},
+		{romaji: "shinokubo", want: "しのくぼ"},
+		// m before b, m or p is ん
+		{romaji: "shimbashi", want: "しんばし"},
+		// Doubled consonants and tch are っ
+		{romaji: "sapporo", want: "さっぽろ"},
+		{romaji: "hatchobori", want: "はっちょぼり"},
+		{romaji: "meiji-jingumae", want: "めいじじんぐまえ"},
+		{romaji: "JR", want: "jr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.romaji, func(t *testing.T) {
+			if got := RomajiToHiragana(tt.romaji); got != tt.want {
+				t.Errorf("RomajiToHiragana(%q) = %q, want %q", tt.romaji, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRomajiReadings(t *testing.T) {
+	tests := []struct {
+		romaji string
+		// want is the reading expected among the first three
+		want string
+	}{
+		// Passport romaji drops long vowels; contracted o is usually long
+		{romaji: "tokyo", want: "とうきょう"},
+		{romaji: "hamamatsucho", want: "はままつちょう"},
+		{romaji: "ryogoku", want: "りょうごく"},
+		{romaji: "jimbocho", want: "じんぼうちょう"},
+		{romaji: "shinjuku", want: "しんじゅく"},
+		// and a long o after o is おお
+		{romaji: "osaka", want: "おおさか"},
+		{romaji: "shin'okubo", want: "しんおおくぼ"},
+		// and the apostrophe of a syllabic n
+		{romaji: "kanichi", want: "かんいち"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.romaji, func(t *testing.T) {
+			readings := RomajiReadings(tt.romaji)
+			if !slices.Contains(readings[:min(len(readings), 3)], tt.want) {
+				t.Errorf("RomajiReadings(%q) = %q, want %q among the first three", tt.romaji, readings, tt.want)
+			}
+			if len(readings) > maxReadings {
+				t.Errorf("RomajiReadings(%q) returned %d readings", tt.romaji, len(readings))
+			}
+		})
+	}
+
+	// Long spellings come first
+	if got := RomajiReadings("kyobashi")[0]; got != "きょうばし" {
+		t.Errorf("first reading of kyobashi = %q, want きょうばし", got)
+	}
+	// Every reading is searched for, however unlikely
+	if got := RomajiReadings("shinokubo"); !slices.Contains(got, "しんおおくぼ") {
+		t.Errorf("RomajiReadings(shinokubo) = %q, missing しんおおくぼ", got)
+	}
+	// A word never starts with ん
+	if got := RomajiReadings("nippori"); slices.ContainsFunc(got, func(r string) bool { return strings.HasPrefix(r, "ん") }) {
+		t.Errorf("RomajiReadings(nippori) = %q, want no reading starting with ん", got)
+	}
+
+	// Unambiguous romaji has one reading
+	for _, romaji := range []string{"toukyou", "oosaka", "shin'yamaguchi", "kanda", "shibuya"} {
+		if got := RomajiReadings(romaji); len(got) != 1 {
+			t.Errorf("RomajiReadings(%q) = %q, want one reading", romaji, got)
+		}
+	}
+}
+
+func TestRomanize(t *testing.T) {
+	tests := []struct {
+		kana  string
+		style Style
+		want  string
+	}{
+		{kana: "とうきょう", style: Hepburn, want: "tōkyō"},
+		{kana: "とうきょう", style: Passport, want: "tokyo"},
+		{kana: "とうきょう", style: PassportOH, want: "tohkyoh"},
+		{kana: "とうきょう", style: Kunrei, want: "tôkyô"},
+		{kana: "とうきょう", style: Wapuro, want: "toukyou"},
+		{kana: "おおさか", style: Hepburn, want: "ōsaka"},
+		{kana: "おおさか", style: Wapuro, want: "oosaka"},
+		// Katakana and the long vowel mark
+		{kana: "ラーメン", style: Hepburn, want: "rāmen"},
+		{kana: "ラーメン", style: Wapuro, want: "ra-men"},
+		// Syllabic n before a vowel or y
+		{kana: "しんおおくぼ", style: Hepburn, want: "shin'ōkubo"},
+		{kana: "しんおおくぼ", style: Passport, want: "shinokubo"},
+		{kana: "かんよう", style: Kunrei, want: "kan'yô"},
+		{kana: "かんよう", style: Wapuro, want: "kannyou"},
+		// n before b, m or p is m in passport Hepburn only
+		{kana: "しんばし", style: Passport, want: "shimbashi"},
+		{kana: "しんばし", style: Hepburn, want: "shinbashi"},
+		// っ doubles the next consonant; Hepburn writes っち as tch
+		{kana: "さっぽろ", style: Hepburn, want: "sapporo"},
+		{kana: "はっちょうぼり", style: Hepburn, want: "hatchōbori"},
+		{kana: "はっちょうぼり", style: Kunrei, want: "hattyôbori"},
+		{kana: "しんじゅく", style: Kunrei, want: "sinzyuku"},
+		{kana: "JR しんじゅく", style: Hepburn, want: "JR shinjuku"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style)+"/"+tt.kana, func(t *testing.T) {
+			if got := Romanize(tt.kana, tt.style); got != tt.want {
+				t.Errorf("Romanize(%q, %q) = %q, want %q", tt.kana, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFoldLongVowels(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "とうきょう", want: "ときょ"},
+		{text: "ときょ", want: "ときょ"},
+		{text: "オオサカ", want: "おさか"},
+		{text: "ラーメン", want: "らめん"},
+	}
+	for _, tt := range tests {
+		if got := FoldLongVowels(tt.text); got != tt.want {
+			t.Errorf("FoldLongVowels(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
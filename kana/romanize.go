@@ -0,0 +1,208 @@
+package kana
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Style is a romanization system
+type Style string
+
+// Supported romanization styles
+const (
+	// Hepburn is modified Hepburn with macrons for long vowels (Tōkyō, Ōsaka)
+	Hepburn Style = "hepburn"
+	// Passport is passport Hepburn: long vowels dropped and m before b/m/p (Tokyo, Osaka, Shimbashi)
+	Passport Style = "passport"
+	// PassportOH is passport Hepburn writing long o as "oh" (Tohkyoh, Ohsaka)
+	PassportOH Style = "passport-oh"
+	// Kunrei is Kunrei-shiki with circumflexes for long vowels (Tôkyô, Sinzyuku)
+	Kunrei Style = "kunrei"
+	// Wapuro is plain ASCII that mirrors the kana spelling (toukyou, oosaka)
+	Wapuro Style = "wapuro"
+)
+
+// Styles lists every supported romanization style
+var Styles = []Style{Hepburn, Passport, PassportOH, Kunrei, Wapuro}
+
+// ParseStyle returns the style named s
+func ParseStyle(s string) (Style, bool) {
+	for _, style := range Styles {
+		if string(style) == s {
+			return style, true
+		}
+	}
+	return "", false
+}
+
+// syllable spellings: Hepburn first, Kunrei-shiki second
+var syllables = map[string][2]string{
+	"あ": {"a", "a"}, "い": {"i", "i"}, "う": {"u", "u"}, "え": {"e", "e"}, "お": {"o", "o"},
+	"か": {"ka", "ka"}, "き": {"ki", "ki"}, "く": {"ku", "ku"}, "け": {"ke", "ke"}, "こ": {"ko", "ko"},
+	"さ": {"sa", "sa"}, "し": {"shi", "si"}, "す": {"su", "su"}, "せ": {"se", "se"}, "そ": {"so", "so"},
+	"た": {"ta", "ta"}, "ち": {"chi", "ti"}, "つ": {"tsu", "tu"}, "て": {"te", "te"}, "と": {"to", "to"},
+	"な": {"na", "na"}, "に": {"ni", "ni"}, "ぬ": {"nu", "nu"}, "ね": {"ne", "ne"}, "の": {"no", "no"},
+	"は": {"ha", "ha"}, "ひ": {"hi", "hi"}, "ふ": {"fu", "hu"}, "へ": {"he", "he"}, "ほ": {"ho", "ho"},
+	"ま": {"ma", "ma"}, "み": {"mi", "mi"}, "む": {"mu", "mu"}, "め": {"me", "me"}, "も": {"mo", "mo"},
+	"や": {"ya", "ya"}, "ゆ": {"yu", "yu"}, "よ": {"yo", "yo"},
+	"ら": {"ra", "ra"}, "り": {"ri", "ri"}, "る": {"ru", "ru"}, "れ": {"re", "re"}, "ろ": {"ro", "ro"},
+	"わ": {"wa", "wa"}, "ゐ": {"i", "i"}, "ゑ": {"e", "e"}, "を": {"o", "o"},
+	"が": {"ga", "ga"}, "ぎ": {"gi", "gi"}, "ぐ": {"gu", "gu"}, "げ": {"ge", "ge"}, "ご": {"go", "go"},
+	"ざ": {"za", "za"}, "じ": {"ji", "zi"}, "ず": {"zu", "zu"}, "ぜ": {"ze", "ze"}, "ぞ": {"zo", "zo"},
+	"だ": {"da", "da"}, "ぢ": {"ji", "zi"}, "づ": {"zu", "zu"}, "で": {"de", "de"}, "ど": {"do", "do"},
+	"ば": {"ba", "ba"}, "び": {"bi", "bi"}, "ぶ": {"bu", "bu"}, "べ": {"be", "be"}, "ぼ": {"bo", "bo"},
+	"ぱ": {"pa", "pa"}, "ぴ": {"pi", "pi"}, "ぷ": {"pu", "pu"}, "ぺ": {"pe", "pe"}, "ぽ": {"po", "po"},
+	"ゔ":  {"vu", "vu"},
+	"きゃ": {"kya", "kya"}, "きゅ": {"kyu", "kyu"}, "きょ": {"kyo", "kyo"},
+	"しゃ": {"sha", "sya"}, "しゅ": {"shu", "syu"}, "しぇ": {"she", "sye"}, "しょ": {"sho", "syo"},
+	"ちゃ": {"cha", "tya"}, "ちゅ": {"chu", "tyu"}, "ちぇ": {"che", "tye"}, "ちょ": {"cho", "tyo"},
+	"にゃ": {"nya", "nya"}, "にゅ": {"nyu", "nyu"}, "にょ": {"nyo", "nyo"},
+	"ひゃ": {"hya", "hya"}, "ひゅ": {"hyu", "hyu"}, "ひょ": {"hyo", "hyo"},
+	"みゃ": {"mya", "mya"}, "みゅ": {"myu", "myu"}, "みょ": {"myo", "myo"},
+	"りゃ": {"rya", "rya"}, "りゅ": {"ryu", "ryu"}, "りょ": {"ryo", "ryo"},
+	"ぎゃ": {"gya", "gya"}, "ぎゅ": {"gyu", "gyu"}, "ぎょ": {"gyo", "gyo"},
+	"じゃ": {"ja", "zya"}, "じゅ": {"ju", "zyu"}, "じぇ": {"je", "zye"}, "じょ": {"jo", "zyo"},
+	"びゃ": {"bya", "bya"}, "びゅ": {"byu", "byu"}, "びょ": {"byo", "byo"},
+	"ぴゃ": {"pya", "pya"}, "ぴゅ": {"pyu", "pyu"}, "ぴょ": {"pyo", "pyo"},
+	"ふぁ": {"fa", "fa"}, "ふぃ": {"fi", "fi"}, "ふぇ": {"fe", "fe"}, "ふぉ": {"fo", "fo"},
+	"うぃ": {"wi", "wi"}, "うぇ": {"we", "we"},
+	"ぁ": {"a", "a"}, "ぃ": {"i", "i"}, "ぅ": {"u", "u"}, "ぇ": {"e", "e"}, "ぉ": {"o", "o"},
+}
+
+// Long vowel marks per style
+var (
+	macrons      = map[byte]string{'a': "ā", 'i': "ii", 'u': "ū", 'e': "ē", 'o': "ō"}
+	circumflexes = map[byte]string{'a': "â", 'i': "î", 'u': "û", 'e': "ê", 'o': "ô"}
+)
+
+// Romanize converts kana (hiragana or katakana) to romaji in the given style
+// Runes that are not kana, such as spaces and Latin letters, are kept unchanged
+func Romanize(text string, style Style) string {
+	text = KatakanaToHiragana(text)
+
+	// First pass: split into syllables, keeping っ, ん and ー as markers
+	tokens := make([]string, 0, len(text))
+	isKana := make([]bool, 0, len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if i+size < len(text) {
+			_, nextSize := utf8.DecodeRuneInString(text[i+size:])
+			if _, ok := syllables[text[i:i+size+nextSize]]; ok {
+				tokens = append(tokens, text[i:i+size+nextSize])
+				isKana = append(isKana, true)
+				i += size + nextSize
+				continue
+			}
+		}
+		_, known := syllables[string(r)]
+		tokens = append(tokens, string(r))
+		isKana = append(isKana, known || r == 'っ' || r == 'ん' || r == 'ー')
+		i += size
+	}
+
+	spell := func(token string) string {
+		if spellings, ok := syllables[token]; ok {
+			if style == Kunrei {
+				return spellings[1]
+			}
+			return spellings[0]
+		}
+		return ""
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !isKana[i] {
+			out.WriteString(token)
+			continue
+		}
+
+		next := ""
+		if i+1 < len(tokens) && isKana[i+1] {
+			next = spell(tokens[i+1])
+		}
+
+		switch token {
+		case "っ":
+			// Double the next consonant; Hepburn writes っち as tch
+			if next == "" || isVowel(next[0]) {
+				continue
+			}
+			if style != Kunrei && style != Wapuro && strings.HasPrefix(next, "ch") {
+				out.WriteByte('t')
+			} else {
+				out.WriteByte(next[0])
+			}
+			continue
+		case "ん":
+			switch {
+			case style == Passport || style == PassportOH:
+				if next != "" && strings.ContainsRune("bmp", rune(next[0])) {
+					out.WriteByte('m')
+				} else {
+					out.WriteByte('n')
+				}
+			case next != "" && (isVowel(next[0]) || next[0] == 'y'):
+				if style == Wapuro {
+					out.WriteString("nn")
+				} else {
+					out.WriteString("n'")
+				}
+			default:
+				out.WriteByte('n')
+			}
+			continue
+		case "ー":
+			// Handled as part of the preceding syllable
+			continue
+		}
+
+		romaji := spell(token)
+		vowel := romaji[len(romaji)-1]
+
+		// Collapse a following long-vowel kana or ー into this syllable
+		long := false
+		if i+1 < len(tokens) && isKana[i+1] {
+			following := tokens[i+1]
+			switch {
+			case following == "ー":
+				long = true
+			case vowel == 'o' && (following == "う" || following == "お"):
+				long = true
+			case vowel == 'u' && following == "う":
+				long = true
+			case vowel == 'a' && following == "あ":
+				long = true
+			}
+		}
+		if !long {
+			out.WriteString(romaji)
+			continue
+		}
+		i++
+
+		stem := romaji[:len(romaji)-1]
+		switch style {
+		case Hepburn:
+			out.WriteString(stem + macrons[vowel])
+		case Kunrei:
+			out.WriteString(stem + circumflexes[vowel])
+		case PassportOH:
+			if vowel == 'o' {
+				out.WriteString(stem + "oh")
+			} else {
+				out.WriteString(romaji)
+			}
+		case Passport:
+			out.WriteString(romaji)
+		default:
+			if tokens[i] == "ー" {
+				out.WriteString(romaji + "-")
+			} else {
+				out.WriteString(romaji + spell(tokens[i]))
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Defaults match the original OpenAI-only agent
+const (
+	DefaultModel       = "gpt-4o"
+	DefaultTimeout     = 10 * time.Second
+	DefaultTemperature = 0.0
+)
+
+// Config selects the provider and model
+// BaseURL points at any OpenAI-compatible server, e.g. llama.cpp or Ollama;
+// empty means api.openai.com
+type Config struct {
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Timeout     time.Duration
+	Temperature float64
+}
+
+// ConfigFromEnv reads LLM_BASE_URL, LLM_MODEL, LLM_TIMEOUT, LLM_TEMPERATURE
+// and OPENAI_API_KEY. A key is only required without LLM_BASE_URL, since
+// local servers usually don't check one.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		BaseURL:     os.Getenv("LLM_BASE_URL"),
+		APIKey:      os.Getenv("OPENAI_API_KEY"),
+		Model:       DefaultModel,
+		Timeout:     DefaultTimeout,
+		Temperature: DefaultTemperature,
+	}
+	if config.BaseURL == "" && config.APIKey == "" {
+		return config, errors.New("OPENAI_API_KEY or LLM_BASE_URL is required")
+	}
+
+	if model := os.Getenv("LLM_MODEL"); model != "" {
+		config.Model = model
+	}
+	if timeout := os.Getenv("LLM_TIMEOUT"); timeout != "" {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			return config, fmt.Errorf("invalid LLM_TIMEOUT %q", timeout)
+		}
+		config.Timeout = parsed
+	}
+	if temperature := os.Getenv("LLM_TEMPERATURE"); temperature != "" {
+		parsed, err := strconv.ParseFloat(temperature, 64)
+		if err != nil || parsed < 0 || parsed > 2 {
+			return config, fmt.Errorf("invalid LLM_TEMPERATURE %q", temperature)
+		}
+		config.Temperature = parsed
+	}
+	return config, nil
+}
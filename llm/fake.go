@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrScriptExhausted is returned when a Fake has no replies left
+var ErrScriptExhausted = errors.New("llm: fake script exhausted")
+
+// Fake is a scripted Client for development and tests
+// Each Complete call returns the next reply and records the request
+type Fake struct {
+	mu       sync.Mutex
+	replies  []Response
+	Requests []Request
+}
+
+// NewFake creates a Fake that answers with replies in order
+func NewFake(replies ...Response) *Fake {
+	return &Fake{replies: replies}
+}
+
+// Complete returns the next scripted reply
+func (f *Fake) Complete(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Requests = append(f.Requests, req)
+	if len(f.replies) == 0 {
+		return nil, ErrScriptExhausted
+	}
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+	return &reply, nil
+}
+
+// Reply returns a scripted assistant reply with content
+func Reply(content string) Response {
+	return Response{Message: Message{Role: RoleAssistant, Content: content}}
+}
+
+// Refuse returns a scripted refusal with reason
+func Refuse(reason string) Response {
+	return Response{Message: Message{Role: RoleAssistant}, Refusal: reason}
+}
+
+// CallTools returns a scripted assistant reply requesting tool calls
+func CallTools(calls ...ToolCall) Response {
+	return Response{Message: Message{Role: RoleAssistant, ToolCalls: calls}}
+}
+
+// Stream returns the next scripted reply, passing its content to onDelta
+// one word at a time
+func (f *Fake) Stream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	response, err := f.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for word := range strings.SplitAfterSeq(response.Message.Content, " ") {
+		if word != "" {
+			onDelta(word)
+		}
+	}
+	return response, nil
+}
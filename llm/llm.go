@@ -0,0 +1,108 @@
+package llm
+
+import "context"
+
+// Role is the author of a chat message
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message is one chat message
+// Assistant messages may carry tool calls; tool messages answer one by ToolCallID
+type Message struct {
+	Role       Role
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallID string
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Tool is a function the model may call, described by a JSON schema
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// Request is one chat completion request
+type Request struct {
+	Messages []Message
+	Tools    []Tool
+	// JSONObject asks for a JSON object as the reply
+	JSONObject bool
+	// Schema enforces a JSON schema on the reply; it takes precedence over JSONObject
+	Schema *Schema
+	// MaxTokens limits the completion, 0 for the provider default
+	MaxTokens int
+}
+
+// Usage is the token count of one completion
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// Response is the model's reply
+// Refusal explains why the model declined, in place of schema output
+type Response struct {
+	Message Message
+	Refusal string
+	Usage   Usage
+}
+
+// Client is a chat completion provider
+type Client interface {
+	Complete(ctx context.Context, req Request) (*Response, error)
+}
+
+// Streamer is a Client that can stream content as it is generated
+type Streamer interface {
+	Stream(ctx context.Context, req Request, onDelta func(delta string)) (*Response, error)
+}
+
+// CompleteStream completes req, passing content deltas to onDelta as they
+// arrive when client is a Streamer, or the whole content at once otherwise
+func CompleteStream(ctx context.Context, client Client, req Request, onDelta func(delta string)) (*Response, error) {
+	if streamer, ok := client.(Streamer); ok {
+		return streamer.Stream(ctx, req, onDelta)
+	}
+	response, err := client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if response.Message.Content != "" {
+		onDelta(response.Message.Content)
+	}
+	return response, nil
+}
+
+// System returns a system message
+func System(content string) Message {
+	return Message{Role: RoleSystem, Content: content}
+}
+
+// User returns a user message
+func User(content string) Message {
+	return Message{Role: RoleUser, Content: content}
+}
+
+// Assistant returns an assistant message
+func Assistant(content string) Message {
+	return Message{Role: RoleAssistant, Content: content}
+}
+
+// ToolResult returns the answer to a tool call
+func ToolResult(callID, content string) Message {
+	return Message{Role: RoleTool, Content: content, ToolCallID: callID}
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// OpenAI is a Client for the OpenAI API or any OpenAI-compatible server
+type OpenAI struct {
+	client openai.Client
+	config Config
+}
+
+// NewOpenAI creates an OpenAI client from config
+func NewOpenAI(config Config) *OpenAI {
+	options := []option.RequestOption{option.WithAPIKey(config.APIKey)}
+	if config.BaseURL != "" {
+		options = append(options, option.WithBaseURL(config.BaseURL))
+	}
+	return &OpenAI{client: openai.NewClient(options...), config: config}
+}
+
+// Complete sends one chat completion request, bounded by the configured timeout
+func (o *OpenAI) Complete(ctx context.Context, req Request) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.config.Timeout)
+	defer cancel()
+
+	completion, err := o.client.Chat.Completions.New(ctx, o.params(req))
+	if err != nil {
+		return nil, fmt.Errorf("chat completion failed: %w", err)
+	}
+	return toResponse(completion)
+}
+
+// Stream sends one chat completion request and passes content deltas to
+// onDelta as they arrive, bounded by the configured timeout
+func (o *OpenAI) Stream(ctx context.Context, req Request, onDelta func(string)) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.config.Timeout)
+	defer cancel()
+
+	params := o.params(req)
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params)
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	accumulator := openai.ChatCompletionAccumulator{}
+	for stream.Next() {
+		chunk := stream.Current()
+		accumulator.AddChunk(chunk)
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("chat completion stream failed: %w", err)
+	}
+	return toResponse(&accumulator.ChatCompletion)
+}
+
+// toResponse converts the first choice of a completion to a Response
+func toResponse(completion *openai.ChatCompletion) (*Response, error) {
+	if len(completion.Choices) == 0 {
+		return nil, errors.New("no choices in chat completion")
+	}
+
+	message := completion.Choices[0].Message
+	response := &Response{
+		Message: Message{Role: RoleAssistant, Content: message.Content},
+		Refusal: message.Refusal,
+		Usage: Usage{
+			PromptTokens:     completion.Usage.PromptTokens,
+			CompletionTokens: completion.Usage.CompletionTokens,
+		},
+	}
+	for _, call := range message.ToolCalls {
+		response.Message.ToolCalls = append(response.Message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return response, nil
+}
+
+// params converts a Request to openai-go parameters
+func (o *OpenAI) params(req Request) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:       o.config.Model,
+		Temperature: openai.Float(o.config.Temperature),
+	}
+	for _, message := range req.Messages {
+		params.Messages = append(params.Messages, messageParam(message))
+	}
+	for _, tool := range req.Tools {
+		params.Tools = append(params.Tools, openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:        tool.Name,
+			Description: openai.String(tool.Description),
+			Parameters:  tool.Parameters,
+		}))
+	}
+	switch {
+	case req.Schema != nil:
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   req.Schema.Name,
+					Strict: openai.Bool(true),
+					Schema: req.Schema.Schema,
+				},
+			},
+		}
+	case req.JSONObject:
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		}
+	}
+	if req.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+	}
+	return params
+}
+
+// messageParam converts a Message to its openai-go union
+func messageParam(message Message) openai.ChatCompletionMessageParamUnion {
+	switch message.Role {
+	case RoleSystem:
+		return openai.SystemMessage(message.Content)
+	case RoleTool:
+		return openai.ToolMessage(message.Content, message.ToolCallID)
+	case RoleAssistant:
+		assistant := openai.ChatCompletionAssistantMessageParam{}
+		if message.Content != "" {
+			assistant.Content.OfString = openai.String(message.Content)
+		}
+		for _, call := range message.ToolCalls {
+			assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+				OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+					ID: call.ID,
+					Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+						Name:      call.Name,
+						Arguments: call.Arguments,
+					},
+				},
+			})
+		}
+		return openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant}
+	default:
+		return openai.UserMessage(message.Content)
+	}
+}
@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema is a named JSON schema the reply must follow
+type Schema struct {
+	Name   string
+	Schema map[string]any
+}
+
+// SchemaFor generates a strict JSON schema from the exported fields of the
+// struct v, named by their json tags. Every field is required and no other
+// properties are allowed, as strict structured output demands.
+// An enum:"a,b" tag restricts a string field or the items of a string slice;
+// desc:"..." describes a field.
+func SchemaFor(name string, v any) Schema {
+	return Schema{Name: name, Schema: schemaOf(reflect.TypeOf(v))}
+}
+
+// schemaOf returns the schema for t; it panics on types JSON can't express,
+// since schemas are built from fixed types at startup
+func schemaOf(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaOf(field.Type)
+			if enum := field.Tag.Get("enum"); enum != "" {
+				// On a slice the enum restricts each item
+				target := property
+				if items, ok := property["items"].(map[string]any); ok {
+					target = items
+				}
+				target["enum"] = strings.Split(enum, ",")
+			}
+			if desc := field.Tag.Get("desc"); desc != "" {
+				property["description"] = desc
+			}
+			properties[name] = property
+			required = append(required, name)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		panic(fmt.Sprintf("llm: no JSON schema for %s", t))
+	}
+}
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	type stop struct {
+		Name string `json:"name"`
+	}
+	type plan struct {
+		Status  string   `json:"status" enum:"ok,ambiguous" desc:"Outcome"`
+		Stops   []stop   `json:"stops"`
+		Avoid   []string `json:"avoid,omitempty" enum:"bus,"`
+		Minutes int      `json:"minutes"`
+		Direct  bool
+		hidden  string
+		Skipped string `json:"-"`
+	}
+
+	got, err := json.Marshal(SchemaFor("plan", plan{}).Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"additionalProperties":false,"properties":{` +
+		`"Direct":{"type":"boolean"},` +
+		`"avoid":{"items":{"enum":["bus",""],"type":"string"},"type":"array"},` +
+		`"minutes":{"type":"integer"},` +
+		`"status":{"description":"Outcome","enum":["ok","ambiguous"],"type":"string"},` +
+		`"stops":{"items":{"additionalProperties":false,"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"},"type":"array"}` +
+		`},"required":["status","stops","avoid","minutes","Direct"],"type":"object"}`
+	if string(got) != want {
+		t.Errorf("schema =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package locale
+
+// LabelCategory groups the enumerated codes returned by NAVITIME
+type LabelCategory string
+
+// Label categories
+const (
+	LabelMoveType   LabelCategory = "move_type"
+	LabelDirection  LabelCategory = "direction"
+	LabelFareSeason LabelCategory = "fare_season"
+	LabelCurrency   LabelCategory = "currency"
+)
+
+// labels maps each category and code to its localized labels
+var labels = map[LabelCategory]map[string]map[Locale]string{
+	LabelMoveType: {
+		"walk": {
+			Japanese: "徒歩", English: "Walk",
+			SimplifiedChinese: "步行", TraditionalChinese: "步行", Korean: "도보",
+		},
+		"local_train": {
+			Japanese: "各駅停車", English: "Local",
+			SimplifiedChinese: "各站停车", TraditionalChinese: "各站停車", Korean: "완행",
+		},
+		"rapid_train": {
+			Japanese: "快速", English: "Rapid",
+			SimplifiedChinese: "快速", TraditionalChinese: "快速", Korean: "쾌속",
+		},
+		"semiexpress_train": {
+			Japanese: "準急", English: "Semi-express",
+			SimplifiedChinese: "准急", TraditionalChinese: "準急", Korean: "준급행",
+		},
+		"express_train": {
+			Japanese: "急行", English: "Express",
+			SimplifiedChinese: "急行", TraditionalChinese: "急行", Korean: "급행",
+		},
+		"limited_express_train": {
+			Japanese: "特急", English: "Limited express",
+			SimplifiedChinese: "特急", TraditionalChinese: "特急", Korean: "특급",
+		},
+		"superexpress_train": {
+			Japanese: "新幹線", English: "Shinkansen",
+			SimplifiedChinese: "新干线", TraditionalChinese: "新幹線", Korean: "신칸센",
+		},
+		"sleeper_ltdexp": {
+			Japanese: "寝台特急", English: "Sleeper limited express",
+			SimplifiedChinese: "卧铺特急", TraditionalChinese: "臥鋪特急", Korean: "침대 특급",
+		},
+		"liner": {
+			Japanese: "ライナー", English: "Liner",
+			SimplifiedChinese: "Liner", TraditionalChinese: "Liner", Korean: "라이너",
+		},
+		"bus": {
+			Japanese: "路線バス", English: "Bus",
+			SimplifiedChinese: "公交车", TraditionalChinese: "公車", Korean: "버스",
+		},
+		"highway_bus": {
+			Japanese: "高速バス", English: "Highway bus",
+			SimplifiedChinese: "高速巴士", TraditionalChinese: "高速巴士", Korean: "고속버스",
+		},
+		"domestic_flight": {
+			Japanese: "国内線", English: "Domestic flight",
+			SimplifiedChinese: "国内航班", TraditionalChinese: "國內航班", Korean: "국내선",
+		},
+		"ferry": {
+			Japanese: "フェリー", English: "Ferry",
+			SimplifiedChinese: "渡轮", TraditionalChinese: "渡輪", Korean: "페리",
+		},
+	},
+	LabelDirection: {
+		"up": {
+			Japanese: "上り", English: "Inbound",
+			SimplifiedChinese: "上行", TraditionalChinese: "上行", Korean: "상행",
+		},
+		"down": {
+			Japanese: "下り", English: "Outbound",
+			SimplifiedChinese: "下行", TraditionalChinese: "下行", Korean: "하행",
+		},
+	},
+	LabelFareSeason: {
+		"normal": {
+			Japanese: "通常期", English: "Regular season",
+			SimplifiedChinese: "平季", TraditionalChinese: "平季", Korean: "통상기",
+		},
+		"busy": {
+			Japanese: "繁忙期", English: "Peak season",
+			SimplifiedChinese: "旺季", TraditionalChinese: "旺季", Korean: "성수기",
+		},
+		"busiest": {
+			Japanese: "最繁忙期", English: "Busiest season",
+			SimplifiedChinese: "最旺季", TraditionalChinese: "最旺季", Korean: "최성수기",
+		},
+		"offpeak": {
+			Japanese: "閑散期", English: "Off-peak season",
+			SimplifiedChinese: "淡季", TraditionalChinese: "淡季", Korean: "비수기",
+		},
+	},
+	LabelCurrency: {
+		"JPY": {
+			Japanese: "円", English: "Japanese yen",
+			SimplifiedChinese: "日元", TraditionalChinese: "日圓", Korean: "엔",
+		},
+	},
+}
+
+// Label returns the localized label for a code, falling back to English
+// It reports false for codes missing from the catalog
+func Label(loc Locale, category LabelCategory, code string) (string, bool) {
+	translations, ok := labels[category][code]
+	if !ok {
+		return "", false
+	}
+	if label, ok := translations[loc]; ok {
+		return label, true
+	}
+	return translations[English], true
+}
@@ -0,0 +1,42 @@
+package locale
+
+import "testing"
+
+func TestLabelsCoverEveryLocale(t *testing.T) {
+	for category, codes := range labels {
+		for code, translations := range codes {
+			for _, loc := range Supported {
+				if translations[loc] == "" {
+					t.Errorf("%s %s has no %s label", category, code, loc)
+				}
+			}
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		loc      Locale
+		category LabelCategory
+		code     string
+		want     string
+		wantOK   bool
+	}{
+		{name: "Japanese", loc: Japanese, category: LabelMoveType, code: "local_train", want: "各駅停車", wantOK: true},
+		{name: "Korean", loc: Korean, category: LabelDirection, code: "up", want: "상행", wantOK: true},
+		{name: "Traditional Chinese", loc: TraditionalChinese, category: LabelCurrency, code: "JPY", want: "日圓", wantOK: true},
+		{name: "unsupported locale falls back to English", loc: Locale("fr"), category: LabelFareSeason, code: "busy", want: "Peak season", wantOK: true},
+		{name: "unknown code", loc: English, category: LabelMoveType, code: "teleport", wantOK: false},
+		{name: "code from another category", loc: English, category: LabelDirection, code: "walk", wantOK: false},
+		{name: "codes are case-sensitive", loc: English, category: LabelCurrency, code: "jpy", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Label(tt.loc, tt.category, tt.code)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Label(%q, %q, %q) = %q, %v, want %q, %v", tt.loc, tt.category, tt.code, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package locale
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale is a supported response language, as a BCP 47 tag
+type Locale string
+
+// Supported locales
+const (
+	Japanese           Locale = "ja"
+	English            Locale = "en"
+	SimplifiedChinese  Locale = "zh-Hans"
+	TraditionalChinese Locale = "zh-Hant"
+	Korean             Locale = "ko"
+)
+
+// Default is used when neither the lang query nor Accept-Language match
+const Default = Japanese
+
+// Supported lists every locale the API can respond in
+var Supported = []Locale{Japanese, English, SimplifiedChinese, TraditionalChinese, Korean}
+
+// Chinese regions that use Traditional characters
+var traditionalRegions = map[string]bool{"tw": true, "hk": true, "mo": true}
+
+// Normalize maps a language tag such as "en-US" or "zh-TW" to a supported locale
+func Normalize(tag string) (Locale, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(tag)), "-")
+	switch parts[0] {
+	case "ja":
+		return Japanese, true
+	case "en":
+		return English, true
+	case "ko":
+		return Korean, true
+	case "zh":
+		// Script subtag wins over region (zh-Hant-CN is still Traditional)
+		for _, part := range parts[1:] {
+			switch part {
+			case "hant":
+				return TraditionalChinese, true
+			case "hans":
+				return SimplifiedChinese, true
+			}
+		}
+		for _, part := range parts[1:] {
+			if traditionalRegions[part] {
+				return TraditionalChinese, true
+			}
+		}
+		return SimplifiedChinese, true
+	}
+	return "", false
+}
+
+// Weighted is a language range from Accept-Language with its quality value
+type Weighted struct {
+	Tag string
+	Q   float64
+}
+
+// ParseAcceptLanguage parses an Accept-Language header into language ranges
+// ordered by descending quality. Ranges with q=0 or invalid q are dropped.
+func ParseAcceptLanguage(header string) []Weighted {
+	ranges := make([]Weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				q = 0
+			} else {
+				q = parsed
+			}
+		}
+		if q == 0 {
+			continue
+		}
+		ranges = append(ranges, Weighted{Tag: tag, Q: q})
+	}
+
+	// Stable so equal weights keep the client's order
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Q > ranges[j].Q
+	})
+	return ranges
+}
+
+// Negotiate picks the best supported locale from an Accept-Language header
+func Negotiate(header string) (Locale, bool) {
+	for _, weighted := range ParseAcceptLanguage(header) {
+		if weighted.Tag == "*" {
+			return Default, true
+		}
+		if loc, ok := Normalize(weighted.Tag); ok {
+			return loc, true
+		}
+	}
+	return "", false
+}
+
+// FromRequest resolves the response locale for a request
+// An explicit lang query parameter wins over the Accept-Language header
+func FromRequest(r *http.Request) Locale {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if loc, ok := Normalize(lang); ok {
+			return loc
+		}
+	}
+	if loc, ok := Negotiate(r.Header.Get("Accept-Language")); ok {
+		return loc
+	}
+	return Default
+}
+
+// String returns the BCP 47 tag
+func (l Locale) String() string {
+	return string(l)
+}
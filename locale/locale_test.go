@@ -0,0 +1,140 @@
+package locale
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   Locale
+		wantOK bool
+	}{
+		{tag: "ja", want: Japanese, wantOK: true},
+		{tag: "ja-JP", want: Japanese, wantOK: true},
+		{tag: " EN-us ", want: English, wantOK: true},
+		{tag: "en-GB", want: English, wantOK: true},
+		{tag: "ko-KR", want: Korean, wantOK: true},
+		{tag: "zh", want: SimplifiedChinese, wantOK: true},
+		{tag: "zh-CN", want: SimplifiedChinese, wantOK: true},
+		{tag: "zh-SG", want: SimplifiedChinese, wantOK: true},
+		{tag: "zh-Hans", want: SimplifiedChinese, wantOK: true},
+		{tag: "zh-TW", want: TraditionalChinese, wantOK: true},
+		{tag: "zh-HK", want: TraditionalChinese, wantOK: true},
+		{tag: "zh-MO", want: TraditionalChinese, wantOK: true},
+		{tag: "zh-Hant", want: TraditionalChinese, wantOK: true},
+		// The script subtag wins over the region
+		{tag: "zh-Hant-CN", want: TraditionalChinese, wantOK: true},
+		{tag: "zh-Hans-TW", want: SimplifiedChinese, wantOK: true},
+		{tag: "fr", wantOK: false},
+		{tag: "english", wantOK: false},
+		{tag: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := Normalize(tt.tag)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Normalize(%q) = %q, %v, want %q, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []Weighted
+	}{
+		{name: "empty", header: "", want: []Weighted{}},
+		{name: "no q is 1", header: "en", want: []Weighted{{Tag: "en", Q: 1}}},
+		{
+			name:   "ordered by quality",
+			header: "fr;q=0.3, ko;q=0.8,en-US",
+			want:   []Weighted{{Tag: "en-US", Q: 1}, {Tag: "ko", Q: 0.8}, {Tag: "fr", Q: 0.3}},
+		},
+		{
+			name:   "equal weights keep the client's order",
+			header: "ko;q=0.5, zh-TW;q=0.5, en;q=0.5",
+			want:   []Weighted{{Tag: "ko", Q: 0.5}, {Tag: "zh-TW", Q: 0.5}, {Tag: "en", Q: 0.5}},
+		},
+		{
+			name:   "q=0 is dropped",
+			header: "en;q=0, ja;q=0.1",
+			want:   []Weighted{{Tag: "ja", Q: 0.1}},
+		},
+		{
+			name:   "invalid q is dropped",
+			header: "en;q=high, ko;q=1.5, zh;q=-1, ja",
+			want:   []Weighted{{Tag: "ja", Q: 1}},
+		},
+		{
+			name:   "spaces and other parameters",
+			header: " en ; level=1 ; q = 0.7 ,, ja ",
+			want:   []Weighted{{Tag: "ja", Q: 1}, {Tag: "en", Q: 0.7}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAcceptLanguage(tt.header); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Locale
+		wantOK bool
+	}{
+		{header: "en-US,en;q=0.9", want: English, wantOK: true},
+		// Unsupported languages are skipped for the next best
+		{header: "fr-FR, de;q=0.9, zh-TW;q=0.8, en;q=0.7", want: TraditionalChinese, wantOK: true},
+		{header: "en;q=0.2, ko;q=0.9", want: Korean, wantOK: true},
+		{header: "zh-Hans-CN", want: SimplifiedChinese, wantOK: true},
+		// A wildcard accepts the default
+		{header: "fr, *;q=0.5", want: Default, wantOK: true},
+		{header: "fr, de", wantOK: false},
+		{header: "en;q=0", wantOK: false},
+		{header: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := Negotiate(tt.header)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Negotiate(%q) = %q, %v, want %q, %v", tt.header, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		want           Locale
+	}{
+		{name: "lang wins over header", query: "lang=ko", acceptLanguage: "en", want: Korean},
+		{name: "regional lang", query: "lang=zh-TW", want: TraditionalChinese},
+		{name: "unsupported lang falls back to header", query: "lang=fr", acceptLanguage: "en", want: English},
+		{name: "header only", acceptLanguage: "zh-CN,zh;q=0.9", want: SimplifiedChinese},
+		{name: "nothing supported", query: "lang=fr", acceptLanguage: "de", want: Japanese},
+		{name: "neither", want: Default},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/transit?"+tt.query, nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if got := FromRequest(r); got != tt.want {
+				t.Errorf("FromRequest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
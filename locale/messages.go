@@ -0,0 +1,120 @@
+package locale
+
+// MessageID identifies a user-facing message
+type MessageID string
+
+// User-facing error messages
+const (
+	MsgMissingParameter  MessageID = "missing_parameter"
+	MsgInvalidParameter  MessageID = "invalid_parameter"
+	MsgStationNotFound   MessageID = "station_not_found"
+	MsgUpstreamFailed    MessageID = "upstream_failed"
+	MsgTranslationFailed MessageID = "translation_failed"
+	MsgInternalError     MessageID = "internal_error"
+	MsgRouteNotFound     MessageID = "route_not_found"
+	MsgAgentFailed       MessageID = "agent_failed"
+	MsgSessionNotFound   MessageID = "session_not_found"
+	MsgBudgetExceeded    MessageID = "budget_exceeded"
+	MsgPromptTooLong     MessageID = "prompt_too_long"
+	MsgPromptRejected    MessageID = "prompt_rejected"
+)
+
+// messages holds every message for every supported locale
+var messages = map[MessageID]map[Locale]string{
+	MsgMissingParameter: {
+		Japanese:           "必須パラメータが指定されていません",
+		English:            "A required parameter is missing",
+		SimplifiedChinese:  "缺少必需的参数",
+		TraditionalChinese: "缺少必要的參數",
+		Korean:             "필수 매개변수가 없습니다",
+	},
+	MsgInvalidParameter: {
+		Japanese:           "パラメータの値が不正です",
+		English:            "A parameter has an invalid value",
+		SimplifiedChinese:  "参数值无效",
+		TraditionalChinese: "參數值無效",
+		Korean:             "매개변수 값이 올바르지 않습니다",
+	},
+	MsgStationNotFound: {
+		Japanese:           "駅が見つかりませんでした",
+		English:            "Station not found",
+		SimplifiedChinese:  "未找到车站",
+		TraditionalChinese: "找不到車站",
+		Korean:             "역을 찾을 수 없습니다",
+	},
+	MsgUpstreamFailed: {
+		Japanese:           "経路情報の取得に失敗しました",
+		English:            "Failed to fetch transit data",
+		SimplifiedChinese:  "获取交通信息失败",
+		TraditionalChinese: "取得交通資訊失敗",
+		Korean:             "교통 정보를 가져오지 못했습니다",
+	},
+	MsgTranslationFailed: {
+		Japanese:           "名称の翻訳に失敗しました",
+		English:            "Failed to translate names",
+		SimplifiedChinese:  "名称翻译失败",
+		TraditionalChinese: "名稱翻譯失敗",
+		Korean:             "이름을 번역하지 못했습니다",
+	},
+	MsgInternalError: {
+		Japanese:           "内部エラーが発生しました",
+		English:            "Internal server error",
+		SimplifiedChinese:  "服务器内部错误",
+		TraditionalChinese: "伺服器內部錯誤",
+		Korean:             "내부 서버 오류",
+	},
+	MsgRouteNotFound: {
+		Japanese:           "経路が見つかりませんでした",
+		English:            "No route found",
+		SimplifiedChinese:  "未找到路线",
+		TraditionalChinese: "找不到路線",
+		Korean:             "경로를 찾을 수 없습니다",
+	},
+	MsgAgentFailed: {
+		Japanese:           "AIアシスタントの応答に失敗しました",
+		English:            "The assistant failed to respond",
+		SimplifiedChinese:  "助手未能响应",
+		TraditionalChinese: "助理未能回應",
+		Korean:             "어시스턴트가 응답하지 못했습니다",
+	},
+	MsgSessionNotFound: {
+		Japanese:           "セッションが見つからないか、有効期限が切れています",
+		English:            "Session not found or expired",
+		SimplifiedChinese:  "会话不存在或已过期",
+		TraditionalChinese: "工作階段不存在或已過期",
+		Korean:             "세션이 없거나 만료되었습니다",
+	},
+	MsgBudgetExceeded: {
+		Japanese:           "本日の利用上限に達しました",
+		English:            "The daily usage limit has been reached",
+		SimplifiedChinese:  "已达到今日使用上限",
+		TraditionalChinese: "已達到今日使用上限",
+		Korean:             "오늘의 사용 한도에 도달했습니다",
+	},
+	MsgPromptTooLong: {
+		Japanese:           "入力が長すぎます",
+		English:            "The prompt is too long",
+		SimplifiedChinese:  "输入内容过长",
+		TraditionalChinese: "輸入內容過長",
+		Korean:             "입력이 너무 깁니다",
+	},
+	MsgPromptRejected: {
+		Japanese:           "この入力は受け付けられません",
+		English:            "The prompt cannot be accepted",
+		SimplifiedChinese:  "无法接受此输入",
+		TraditionalChinese: "無法接受此輸入",
+		Korean:             "이 입력은 받을 수 없습니다",
+	},
+}
+
+// Message returns the message for id in loc, falling back to English
+func Message(loc Locale, id MessageID) string {
+	translations, ok := messages[id]
+	if !ok {
+		return string(id)
+	}
+	if message, ok := translations[loc]; ok {
+		return message
+	}
+	return translations[English]
+}
@@ -0,0 +1,34 @@
+package locale
+
+import "testing"
+
+func TestMessagesCoverEveryLocale(t *testing.T) {
+	for id, translations := range messages {
+		for _, loc := range Supported {
+			if translations[loc] == "" {
+				t.Errorf("%s has no %s message", id, loc)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  Locale
+		id   MessageID
+		want string
+	}{
+		{name: "Japanese", loc: Japanese, id: MsgStationNotFound, want: "駅が見つかりませんでした"},
+		{name: "Traditional Chinese", loc: TraditionalChinese, id: MsgStationNotFound, want: "找不到車站"},
+		{name: "unsupported locale falls back to English", loc: Locale("fr"), id: MsgStationNotFound, want: "Station not found"},
+		{name: "unknown ID is returned as is", loc: English, id: MessageID("no_such_message"), want: "no_such_message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Message(tt.loc, tt.id); got != tt.want {
+				t.Errorf("Message(%q, %q) = %q, want %q", tt.loc, tt.id, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"transit-api/handler"
+	"transit-api/llm"
 
 	_ "transit-api/docs" // docs are generated by Swag CLI, you have to import it.
 
@@ -28,13 +35,52 @@ import (
 // @host localhost:8080
 // @BasePath /
 
+// shutdownTimeout bounds how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	r := router.NewRouter()
 
+	// Sweep expired cache entries in the background instead of waiting for a Get
+	handler.StartCacheJanitors(time.Minute)
+
+	// Refresh popular routes and stations ahead of the morning rush
+	prewarmConfig, err := handler.LoadPrewarmConfig()
+	if err != nil {
+		fmt.Printf("Prewarm disabled: %v\n", err)
+	} else {
+		handler.StartPrewarm(prewarmConfig)
+	}
+
+	// The transit agent runs on OpenAI or any OpenAI-compatible server
+	llmConfig, err := llm.ConfigFromEnv()
+	if err != nil {
+		fmt.Printf("Transit agent disabled: %v\n", err)
+	} else {
+		handler.UseLLM(llm.NewOpenAI(llmConfig))
+	}
+
+	// Token budgets and prices for agent cost accounting
+	agentBudget, err := handler.LoadAgentBudget()
+	if err != nil {
+		fmt.Printf("Agent budgets disabled: %v\n", err)
+	} else {
+		handler.UseAgentBudget(agentBudget)
+	}
+
+	// Input guard and audit log for prompts sent to the agent
+	agentGuard, err := handler.LoadAgentGuard()
+	if err == nil {
+		err = handler.UseAgentGuard(agentGuard)
+	}
+	if err != nil {
+		fmt.Printf("Agent guard using defaults: %v\n", err)
+	}
+
 	// CORS middleware to allow all origins
 	r.Use(middleware.SimpleCORS())
 
-	r.Use(middleware.EnvVarChecker("RAPIDAPI_KEY", "RAPIDAPI_TRANSPORT_HOST", "RAPIDAPI_TRANSIT_HOST", "OPENAI_API_KEY"))
+	r.Use(middleware.EnvVarChecker("RAPIDAPI_KEY", "RAPIDAPI_TRANSPORT_HOST", "RAPIDAPI_TRANSIT_HOST"))
 
 	// Throttling for all API routes
 	// Throttle: limits concurrent in-flight requests to 1000
@@ -60,10 +106,36 @@ func main() {
 	r.Get("/transit", handler.Transit())
 	r.Get("/autocomplete", handler.Autocomplete)
 	r.Post("/transit-agent", handler.TransitAgent)
+	r.Post("/transit-agent/plan", handler.TransitPlan)
+	r.Post("/transit-agent/stream", handler.TransitAgentStream)
 
-	fmt.Println("Starting server on :8080")
-	err := http.ListenAndServe(":8080", r)
-	if err != nil {
-		return
+	// Cache observability and admin routes (admin requires ADMIN_API_KEY)
+	r.Get("/metrics/cache", handler.CacheMetrics)
+	r.Get("/admin/cache/keys", handler.AdminOnly(handler.CacheKeys))
+	r.Delete("/admin/cache/keys", handler.AdminOnly(handler.CachePurge))
+	r.Get("/admin/cache/entry", handler.AdminOnly(handler.CacheGetEntry))
+	r.Delete("/admin/cache/entry", handler.AdminOnly(handler.CacheDeleteEntry))
+	r.Get("/admin/agent/usage", handler.AdminOnly(handler.AgentUsage))
+
+	// Stop on SIGINT or SIGTERM, letting in-flight requests finish before the
+	// cache janitors are stopped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		fmt.Println("Starting server on :8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Server failed: %v\n", err)
+			stop()
+		}
+	}()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Shutdown failed: %v\n", err)
 	}
+	handler.CloseCaches()
 }
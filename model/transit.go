@@ -27,6 +27,9 @@ type Point struct {
 	Type      string     `json:"type"`
 	Coord     Coordinate `json:"coord"`
 	Name      string     `json:"name"`
+	Ruby      string     `json:"ruby,omitempty"`
+	NameEn    string     `json:"name_en,omitempty"`
+	NameRuby  string     `json:"name_ruby,omitempty"`
 	NodeID    string     `json:"node_id"`
 	NodeTypes []string   `json:"node_types"`
 	Numbering *Numbering `json:"numbering,omitempty"`
@@ -52,14 +55,15 @@ type StationNumber struct {
 
 // Move represents movement details for a route
 type Move struct {
-	TransitCount int       `json:"transit_count"`
-	Fare         Fare      `json:"fare"`
-	Type         string    `json:"type"`
-	FromTime     time.Time `json:"from_time"`
-	ToTime       time.Time `json:"to_time"`
-	Time         int       `json:"time"`
-	Distance     int       `json:"distance"`
-	MoveType     []string  `json:"move_type"`
+	TransitCount   int       `json:"transit_count"`
+	Fare           Fare      `json:"fare"`
+	Type           string    `json:"type"`
+	FromTime       time.Time `json:"from_time"`
+	ToTime         time.Time `json:"to_time"`
+	Time           int       `json:"time"`
+	Distance       int       `json:"distance"`
+	MoveType       []string  `json:"move_type"`
+	MoveTypeLabels []string  `json:"move_type_labels,omitempty"`
 }
 
 // Fare represents fare information for different ticket types
@@ -85,61 +89,83 @@ type Section struct {
 	// Point fields
 	Coord     *Coordinate `json:"coord,omitempty"`
 	Name      string      `json:"name,omitempty"`
+	Ruby      string      `json:"ruby,omitempty"`
+	NameEn    string      `json:"name_en,omitempty"`
+	NameRuby  string      `json:"name_ruby,omitempty"`
 	NodeID    string      `json:"node_id,omitempty"`
 	NodeTypes []string    `json:"node_types,omitempty"`
 	Numbering *Numbering  `json:"numbering,omitempty"`
 
 	// Move fields
-	Transport *Transport `json:"transport,omitempty"`
-	Move      string     `json:"move,omitempty"`
-	FromTime  *time.Time `json:"from_time,omitempty"`
-	ToTime    *time.Time `json:"to_time,omitempty"`
-	Time      int        `json:"time,omitempty"`
-	Distance  int        `json:"distance,omitempty"`
-	LineName  string     `json:"line_name,omitempty"`
+	Transport  *Transport `json:"transport,omitempty"`
+	Move       string     `json:"move,omitempty"`
+	MoveLabel  string     `json:"move_label,omitempty"`
+	FromTime   *time.Time `json:"from_time,omitempty"`
+	ToTime     *time.Time `json:"to_time,omitempty"`
+	Time       int        `json:"time,omitempty"`
+	Distance   int        `json:"distance,omitempty"`
+	LineName   string     `json:"line_name,omitempty"`
+	LineNameEn string     `json:"line_name_en,omitempty"`
 }
 
 // Transport represents transportation details
 type Transport struct {
-	Fare       Fare         `json:"fare"`
-	Color      string       `json:"color"`
-	Name       string       `json:"name"`
-	FareSeason string       `json:"fare_season"`
-	Company    Company      `json:"company"`
-	Links      []Link       `json:"links"`
-	ID         string       `json:"id"`
-	Type       string       `json:"type"`
-	FareBreak  FareBreak    `json:"fare_break"`
-	FareDetail []FareDetail `json:"fare_detail"`
+	Fare            Fare         `json:"fare"`
+	Color           string       `json:"color"`
+	Name            string       `json:"name"`
+	Ruby            string       `json:"ruby,omitempty"`
+	NameEn          string       `json:"name_en,omitempty"`
+	NameRuby        string       `json:"name_ruby,omitempty"`
+	FareSeason      string       `json:"fare_season"`
+	FareSeasonLabel string       `json:"fare_season_label,omitempty"`
+	Company         Company      `json:"company"`
+	Links           []Link       `json:"links"`
+	ID              string       `json:"id"`
+	Type            string       `json:"type"`
+	FareBreak       FareBreak    `json:"fare_break"`
+	FareDetail      []FareDetail `json:"fare_detail"`
 }
 
 // Company represents transportation company information
 type Company struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Ruby     string `json:"ruby,omitempty"`
+	NameEn   string `json:"name_en,omitempty"`
+	NameRuby string `json:"name_ruby,omitempty"`
 }
 
 // Link represents route link information
 type Link struct {
-	ID          string      `json:"id"`
-	Name        string      `json:"name"`
-	Direction   string      `json:"direction"`
-	Destination Destination `json:"destination"`
-	From        Station     `json:"from"`
-	To          Station     `json:"to"`
-	IsTimetable string      `json:"is_timetable"`
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Ruby           string      `json:"ruby,omitempty"`
+	NameEn         string      `json:"name_en,omitempty"`
+	NameRuby       string      `json:"name_ruby,omitempty"`
+	Direction      string      `json:"direction"`
+	DirectionLabel string      `json:"direction_label,omitempty"`
+	Destination    Destination `json:"destination"`
+	From           Station     `json:"from"`
+	To             Station     `json:"to"`
+	IsTimetable    string      `json:"is_timetable"`
 }
 
 // Destination represents link destination
 type Destination struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Ruby     string `json:"ruby,omitempty"`
+	NameEn   string `json:"name_en,omitempty"`
+	NameRuby string `json:"name_ruby,omitempty"`
 }
 
 // Station represents a station in a link
 type Station struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Ruby     string `json:"ruby,omitempty"`
+	NameEn   string `json:"name_en,omitempty"`
+	NameRuby string `json:"name_ruby,omitempty"`
 }
 
 // FareBreak represents fare break information
@@ -164,11 +190,12 @@ type FareDetail struct {
 
 // Unit represents units used in the response
 type Unit struct {
-	Datum     string `json:"datum"`
-	CoordUnit string `json:"coord_unit"`
-	Distance  string `json:"distance"`
-	Time      string `json:"time"`
-	Currency  string `json:"currency"`
+	Datum         string `json:"datum"`
+	CoordUnit     string `json:"coord_unit"`
+	Distance      string `json:"distance"`
+	Time          string `json:"time"`
+	Currency      string `json:"currency"`
+	CurrencyLabel string `json:"currency_label,omitempty"`
 }
 
 // NodeResponse represents the response from the transport_node API
@@ -200,11 +227,36 @@ type AutocompleteStation struct {
 }
 
 // FilteredStation represents a filtered station in autocomplete response
+// Nodes of the same station complex are collapsed into one item, with each
+// node listed in Members
 type FilteredStation struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Ruby string `json:"ruby,omitempty"`
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Ruby    string          `json:"ruby,omitempty"`
+	Members []StationMember `json:"members,omitempty"`
+}
+
+// StationComplex groups the nodes that share one logical station,
+// such as the operator-specific nodes of a transfer hub
+// ID, Ruby and Coord are the unqualified node's ("新宿" rather than
+// "新宿(都営線)") when the complex has one
+type StationComplex struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Ruby    string          `json:"ruby,omitempty"`
+	Coord   Coordinate      `json:"coord"`
+	Members []StationMember `json:"members"`
+}
+
+// StationMember is one node of a station complex
+// Its name's qualifier is the Operator ("新宿(都営線)") or, for a prefecture,
+// the Prefecture ("大手町(群馬県)")
+type StationMember struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Operator   string `json:"operator,omitempty"`
+	Prefecture string `json:"prefecture,omitempty"`
 }
 
 // FilteredAutocompleteResponse represents the filtered autocomplete response
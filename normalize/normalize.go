@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"strings"
+
+	"transit-api/kana"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Small kana variants used interchangeably in place names (市ヶ谷, 市ケ谷)
+var smallKanaVariants = strings.NewReplacer("ヶ", "ケ", "ヵ", "カ")
+
+// Text applies NFKC, unifies ヶ/ケ variants, and trims and collapses whitespace
+// NFKC folds full-width ASCII ("ＪＲ") to "JR", half-width katakana to
+// full-width, and the ideographic space to a regular space
+func Text(s string) string {
+	s = norm.NFKC.String(s)
+	s = smallKanaVariants.Replace(s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Query applies NFKC and trims and collapses whitespace, leaving the text
+// otherwise as typed. It is the spelling sent upstream, which may not know
+// the folded forms Station produces for cache keys ("jr", "しんじゅく").
+func Query(s string) string {
+	return strings.Join(strings.Fields(norm.NFKC.String(s)), " ")
+}
+
+// Station normalizes a station query so every spelling of the same station
+// produces the same cache key. Upstream gets Query's spelling instead, so
+// the spellings sharing a key share whichever upstream answer fills it:
+//   - Text normalization (NFKC, whitespace)
+//   - a trailing 駅 is removed ("新宿駅" -> "新宿")
+//   - kana-only input is folded to hiragana ("シンジュク" -> "しんじゅく")
+//   - romaji input is lowercased ("Shinjuku" -> "shinjuku")
+func Station(s string) string {
+	s = Text(s)
+	if trimmed := strings.TrimSpace(strings.TrimSuffix(s, "駅")); trimmed != "" {
+		s = trimmed
+	}
+	switch {
+	case kana.IsKana(s):
+		return kana.KatakanaToHiragana(s)
+	case kana.IsRomaji(s):
+		return strings.ToLower(s)
+	}
+	return s
+}
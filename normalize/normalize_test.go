@@ -0,0 +1,62 @@
+package normalize
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "unchanged", input: "新宿", want: "新宿"},
+		{name: "full-width latin", input: "ＪＲ山手線", want: "JR山手線"},
+		{name: "full-width digits", input: "羽田空港第３ターミナル", want: "羽田空港第3ターミナル"},
+		{name: "half-width katakana", input: "ｼﾝｼﾞｭｸ", want: "シンジュク"},
+		{name: "half-width voiced katakana", input: "ｶﾞｲｴﾝﾏｴ", want: "ガイエンマエ"},
+		{name: "small ke", input: "市ヶ谷", want: "市ケ谷"},
+		{name: "small ka", input: "霞ヵ関", want: "霞カ関"},
+		{name: "surrounding whitespace", input: "  新宿 \t", want: "新宿"},
+		{name: "ideographic space", input: "新宿　三丁目", want: "新宿 三丁目"},
+		{name: "collapsed whitespace", input: "shin   okubo", want: "shin okubo"},
+		{name: "empty", input: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.input); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStation(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "kanji", input: "新宿", want: "新宿"},
+		{name: "station suffix", input: "新宿駅", want: "新宿"},
+		{name: "station suffix with space", input: "新宿 駅", want: "新宿"},
+		{name: "only station", input: "駅", want: "駅"},
+		{name: "suffix inside name kept", input: "駅前", want: "駅前"},
+		{name: "full-width with suffix", input: "ＪＲ新宿駅", want: "JR新宿"},
+		{name: "small ke with suffix", input: "市ヶ谷駅", want: "市ケ谷"},
+		{name: "katakana folded", input: "シンジュク", want: "しんじゅく"},
+		{name: "half-width katakana folded", input: "ｼﾝｼﾞｭｸ", want: "しんじゅく"},
+		{name: "hiragana unchanged", input: "しんじゅく", want: "しんじゅく"},
+		{name: "long vowel mark kept", input: "スカイツリー", want: "すかいつりー"},
+		{name: "mixed script not folded", input: "押上スカイツリー前", want: "押上スカイツリー前"},
+		{name: "romaji lowercased", input: " Shinjuku ", want: "shinjuku"},
+		{name: "full-width romaji", input: "Ｓｈｉｎｊｕｋｕ", want: "shinjuku"},
+		{name: "latin abbreviation lowercased", input: "JR", want: "jr"},
+		{name: "empty", input: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Station(tt.input); got != tt.want {
+				t.Errorf("Station(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
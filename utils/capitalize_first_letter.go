@@ -1,22 +1,62 @@
 package utils
 
 import (
-	"strings"
 	"unicode"
+
+	"transit-api/kana"
 )
 
-// CapitalizeFirstLetter capitalizes the first letter of each part of a string
-func CapitalizeFirstLetter(text string) string {
-	// Split by parentheses
-	parts := strings.Split(text, "(")
-	for i, part := range parts {
-		part = strings.TrimSpace(part)
-		if len(part) == 0 {
-			continue
+// CapitalizeFirstLetter capitalizes the first letter of each word of a string
+// romanized in style (empty for kanjikana's output).
+// Words start after spaces, hyphens, slashes, middle dots, parentheses and
+// quotes. An apostrophe is a quote unless it is the syllabic n' of Hepburn or
+// Kunrei-shiki (kan'ichi), and a wapuro hyphen is a long vowel (ra-men).
+func CapitalizeFirstLetter(text string, style kana.Style) string {
+	runes := []rune(text)
+	startOfWord := true
+	for i, r := range runes {
+		if startOfWord && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		switch r {
+		case '\'':
+			startOfWord = !isSyllabicN(runes, i, style)
+		case '-':
+			startOfWord = style != kana.Wapuro
+		default:
+			startOfWord = isWordBoundary(r)
 		}
-		runes := []rune(part)
-		runes[0] = unicode.ToUpper(runes[0])
-		parts[i] = string(runes)
 	}
-	return strings.Join(parts, "(")
+	return string(runes)
+}
+
+// isWordBoundary reports whether r separates words in a romanized name
+func isWordBoundary(r rune) bool {
+	switch r {
+	case '/', '(', '（', '・', '·', '〈', '"', '「':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// isSyllabicN reports whether the apostrophe at runes[i] separates ん from a
+// following vowel or y, as only Hepburn and Kunrei-shiki write it
+func isSyllabicN(runes []rune, i int, style kana.Style) bool {
+	switch style {
+	case "", kana.Hepburn, kana.Kunrei:
+	default:
+		return false
+	}
+	if i == 0 || i+1 == len(runes) || unicode.ToLower(runes[i-1]) != 'n' {
+		return false
+	}
+	// n' at the start of a word is a quoted word beginning with n
+	if i >= 2 && !unicode.IsLetter(runes[i-2]) {
+		return false
+	}
+	switch unicode.ToLower(runes[i+1]) {
+	case 'a', 'i', 'u', 'e', 'o', 'y', 'ā', 'ī', 'ū', 'ē', 'ō', 'â', 'î', 'û', 'ê', 'ô':
+		return true
+	}
+	return false
 }
@@ -1,14 +1,27 @@
 package utils
 
 import (
+	"time"
+
+	"transit-api/cache"
+
 	"github.com/jtclarkjr/kanjikana"
 )
 
+// romajiMemo caches kanjikana output by input text
+// Tokenization dominates translation cost and the same station and line
+// names repeat across responses, so results are shared process-wide
+var romajiMemo = cache.NewLRUCache(20000, 24*time.Hour)
+
 // KanjiToRomaji converts Kanji characters in a string to Romaji
 func KanjiToRomaji(text string) (string, error) {
+	if cached, ok := romajiMemo.Get(text); ok {
+		return cached.(string), nil
+	}
 	romaji, err := kanjikana.ConvertKanjiToRomaji(text)
 	if err != nil {
 		return "", err
 	}
+	romajiMemo.Set(text, romaji)
 	return romaji, nil
 }
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"transit-api/locale"
+	"transit-api/model"
+)
+
+// LabelEnums adds localized labels next to the enumerated codes in a
+// TransitResponse: move types, link directions, fare seasons and currency.
+// Codes missing from the catalog are left without a label, except in
+// move_type_labels, which stays index-aligned with move_type by labelling an
+// unknown code with the code itself.
+func LabelEnums(response *model.TransitResponse, loc locale.Locale) {
+	response.Unit.CurrencyLabel = label(loc, locale.LabelCurrency, response.Unit.Currency)
+
+	for i := range response.Items {
+		item := &response.Items[i]
+
+		move := &item.Summary.Move
+		move.MoveTypeLabels = nil
+		for _, code := range move.MoveType {
+			text, ok := locale.Label(loc, locale.LabelMoveType, code)
+			if !ok {
+				text = code
+			}
+			move.MoveTypeLabels = append(move.MoveTypeLabels, text)
+		}
+
+		for j := range item.Sections {
+			section := &item.Sections[j]
+			section.MoveLabel = label(loc, locale.LabelMoveType, section.Move)
+
+			if section.Transport == nil {
+				continue
+			}
+			transport := section.Transport
+			transport.FareSeasonLabel = label(loc, locale.LabelFareSeason, transport.FareSeason)
+			for k := range transport.Links {
+				link := &transport.Links[k]
+				link.DirectionLabel = label(loc, locale.LabelDirection, link.Direction)
+			}
+		}
+	}
+}
+
+// label returns the catalog label for code, or an empty string if unknown
+func label(loc locale.Locale, category locale.LabelCategory, code string) string {
+	text, _ := locale.Label(loc, category, code)
+	return text
+}
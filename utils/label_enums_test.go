@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"transit-api/locale"
+)
+
+func TestLabelEnums(t *testing.T) {
+	response := loadFixture(t, "bilingual.json")
+	move := &response.Items[0].Summary.Move
+	move.MoveType = []string{"walk", "teleport", "local_train"}
+	// Labels from a previous locale are replaced
+	move.MoveTypeLabels = []string{"stale"}
+	response.Items[0].Sections[0].Move = "teleport"
+
+	LabelEnums(&response, locale.English)
+
+	if want := []string{"Walk", "teleport", "Local"}; !slices.Equal(move.MoveTypeLabels, want) {
+		t.Errorf("move_type_labels = %q, want %q", move.MoveTypeLabels, want)
+	}
+	sections := response.Items[0].Sections
+	if sections[0].MoveLabel != "" {
+		t.Errorf("unknown move has label %q", sections[0].MoveLabel)
+	}
+	if sections[1].MoveLabel != "Local" {
+		t.Errorf("move_label = %q, want Local", sections[1].MoveLabel)
+	}
+	transport := sections[1].Transport
+	if transport.FareSeasonLabel != "Regular season" {
+		t.Errorf("fare_season_label = %q", transport.FareSeasonLabel)
+	}
+	if transport.Links[0].DirectionLabel != "Inbound" {
+		t.Errorf("direction_label = %q", transport.Links[0].DirectionLabel)
+	}
+	if response.Unit.CurrencyLabel != "Japanese yen" {
+		t.Errorf("currency_label = %q", response.Unit.CurrencyLabel)
+	}
+
+	LabelEnums(&response, locale.Japanese)
+	if want := []string{"徒歩", "teleport", "各駅停車"}; !slices.Equal(move.MoveTypeLabels, want) {
+		t.Errorf("ja move_type_labels = %q, want %q", move.MoveTypeLabels, want)
+	}
+}
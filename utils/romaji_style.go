@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"strings"
+
+	"transit-api/kana"
+)
+
+// StyledTranslator translates names through a dictionary and renders Romaji
+// in a chosen romanization style. An empty Style keeps kanjikana's output.
+type StyledTranslator struct {
+	Dictionary *DictionaryTranslator
+	Style      kana.Style
+}
+
+// Translate returns the dictionary name adjusted to the style, or styled
+// Romaji when the name is not in the dictionary
+func (t StyledTranslator) Translate(id, name string) (string, error) {
+	return t.TranslateReading(id, name, "")
+}
+
+// TranslateReading is Translate for a name whose kana reading is known
+// A styled name is romanized from the reading rather than from kanjikana's
+// Romaji, which has already lost long vowels and syllable boundaries
+func (t StyledTranslator) TranslateReading(id, name, reading string) (string, error) {
+	if official, ok := t.Dictionary.Lookup(id, name); ok {
+		return StyleOfficialName(official, t.Style), nil
+	}
+	if reading != "" && t.Style != "" {
+		return CapitalizeFirstLetter(kana.Romanize(reading, t.Style), t.Style), nil
+	}
+	romaji, err := KanjiToRomaji(name)
+	if err != nil {
+		return "", err
+	}
+	return CapitalizeFirstLetter(ApplyRomajiStyle(romaji, t.Style), t.Style), nil
+}
+
+// ApplyRomajiStyle re-romanizes kanjikana output in the given style
+// It goes through kana, so only use it for names with no known reading
+func ApplyRomajiStyle(romaji string, style kana.Style) string {
+	if style == "" {
+		return romaji
+	}
+	return kana.Romanize(kana.RomajiToHiragana(romaji), style)
+}
+
+// Long vowel replacements for official names, which are written with macrons
+var (
+	macronsToASCII = strings.NewReplacer(
+		"Ā", "A", "Ē", "E", "Ī", "I", "Ō", "O", "Ū", "U",
+		"ā", "a", "ē", "e", "ī", "i", "ō", "o", "ū", "u",
+	)
+	macronsToOH = strings.NewReplacer(
+		"Ā", "A", "Ē", "E", "Ī", "I", "Ō", "Oh", "Ū", "U",
+		"ā", "a", "ē", "e", "ī", "i", "ō", "oh", "ū", "u",
+	)
+	macronsToCircumflexes = strings.NewReplacer(
+		"Ā", "Â", "Ē", "Ê", "Ī", "Î", "Ō", "Ô", "Ū", "Û",
+		"ā", "â", "ē", "ê", "ī", "î", "ō", "ô", "ū", "û",
+	)
+)
+
+// StyleOfficialName adapts the long vowels of an official name to a style
+// Official spellings are otherwise kept, so Shimbashi stays Shimbashi
+func StyleOfficialName(name string, style kana.Style) string {
+	switch style {
+	case kana.Passport, kana.Wapuro:
+		return macronsToASCII.Replace(name)
+	case kana.PassportOH:
+		return macronsToOH.Replace(name)
+	case kana.Kunrei:
+		return macronsToCircumflexes.Replace(name)
+	default:
+		return name
+	}
+}
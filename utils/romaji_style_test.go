@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"testing"
+
+	"transit-api/kana"
+)
+
+func TestCapitalizeFirstLetter(t *testing.T) {
+	tests := []struct {
+		text  string
+		style kana.Style
+		want  string
+	}{
+		{text: "meiji-jingumae 'harajuku'", style: "", want: "Meiji-Jingumae 'Harajuku'"},
+		{text: "meiji-jingumae 'harajuku'", style: kana.Passport, want: "Meiji-Jingumae 'Harajuku'"},
+		{text: "shin-ōsaka (tōkaidō)", style: kana.Hepburn, want: "Shin-Ōsaka (Tōkaidō)"},
+		{text: "ôtemati/tôkyô", style: kana.Kunrei, want: "Ôtemati/Tôkyô"},
+		{text: "keikyū kamata・yokohama", style: kana.Hepburn, want: "Keikyū Kamata・Yokohama"},
+		// Syllabic n keeps the word going in the styles that write it
+		{text: "kan'ichi", style: kana.Hepburn, want: "Kan'ichi"},
+		{text: "kan'yô", style: kana.Kunrei, want: "Kan'yô"},
+		{text: "kan'ichi", style: "", want: "Kan'ichi"},
+		// Passport and wapuro never write n', so an apostrophe is a quote
+		{text: "kan'ichi", style: kana.Passport, want: "Kan'Ichi"},
+		{text: "shin 'nakano'", style: kana.Hepburn, want: "Shin 'Nakano'"},
+		{text: "'ueno' 'okachimachi'", style: kana.Wapuro, want: "'Ueno' 'Okachimachi'"},
+		// A wapuro hyphen is a long vowel, not a word boundary
+		{text: "ra-men yokochou", style: kana.Wapuro, want: "Ra-men Yokochou"},
+		{text: "ra-men yokochou", style: kana.Hepburn, want: "Ra-Men Yokochou"},
+		{text: "", style: kana.Hepburn, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style)+"/"+tt.text, func(t *testing.T) {
+			if got := CapitalizeFirstLetter(tt.text, tt.style); got != tt.want {
+				t.Errorf("CapitalizeFirstLetter(%q, %q) = %q, want %q", tt.text, tt.style, got, tt.want)
+			}
+		})
+	}
+}
+
+// Names with a reading are romanized from the kana in each style; official
+// names keep their spelling with only the long vowels adapted
+func TestStyledTranslatorReading(t *testing.T) {
+	names := []struct {
+		name, reading string
+	}{
+		{"両国", "りょうごく"},
+		{"大井町", "おおいまち"},
+		{"神保町", "じんぼうちょう"},
+		{"新大塚", "しんおおつか"},
+		{"大手町", "おおてまち"},
+	}
+	tests := []struct {
+		style kana.Style
+		want  []string
+	}{
+		{style: kana.Hepburn, want: []string{"Ryōgoku", "Ōimachi", "Jinbōchō", "Shin'ōtsuka", "Ōtemachi"}},
+		{style: kana.Passport, want: []string{"Ryogoku", "Oimachi", "Jimbocho", "Shinotsuka", "Otemachi"}},
+		{style: kana.PassportOH, want: []string{"Ryohgoku", "Ohimachi", "Jimbohchoh", "Shinohtsuka", "Ohtemachi"}},
+		{style: kana.Kunrei, want: []string{"Ryôgoku", "Ôimati", "Zinbôtyô", "Sin'ôtuka", "Ôtemachi"}},
+		{style: kana.Wapuro, want: []string{"Ryougoku", "Ooimachi", "Jinbouchou", "Shinnootsuka", "Otemachi"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			translator := StyledTranslator{Dictionary: EnglishDictionary, Style: tt.style}
+			for i, name := range names {
+				got, err := translator.TranslateReading("", name.name, name.reading)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("%s (%s) = %q, want %q", name.name, name.reading, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStyleOfficialName(t *testing.T) {
+	tests := []struct {
+		style kana.Style
+		want  string
+	}{
+		{style: "", want: "Shin-Ōsaka"},
+		{style: kana.Hepburn, want: "Shin-Ōsaka"},
+		{style: kana.Passport, want: "Shin-Osaka"},
+		{style: kana.PassportOH, want: "Shin-Ohsaka"},
+		{style: kana.Kunrei, want: "Shin-Ôsaka"},
+		{style: kana.Wapuro, want: "Shin-Osaka"},
+	}
+	for _, tt := range tests {
+		if got := StyleOfficialName("Shin-Ōsaka", tt.style); got != tt.want {
+			t.Errorf("StyleOfficialName(Shin-Ōsaka, %q) = %q, want %q", tt.style, got, tt.want)
+		}
+	}
+}
@@ -1,79 +1,95 @@
 package utils
 
 import (
+	"runtime"
+	"sync"
+
+	"transit-api/kana"
 	"transit-api/model"
 )
 
-// TranslateTypedTransitResponse translates the names in a TransitResponse to Romaji if the language is English
-func TranslateTypedTransitResponse(response *model.TransitResponse) error {
-	for i := range response.Items {
-		item := &response.Items[i]
-
-		// Translate summary names
-		if err := translateString(&item.Summary.Start.Name); err != nil {
-			return err
-		}
-		if err := translateString(&item.Summary.Goal.Name); err != nil {
-			return err
-		}
-
-		// Translate sections
-		for j := range item.Sections {
-			section := &item.Sections[j]
+// TranslateOptions controls how translated names are written to a response
+type TranslateOptions struct {
+	// Lang selects the dictionary used to overwrite names (en, zh-Hans, zh-Hant, ko)
+	Lang string
+	// Bilingual keeps the Japanese names and fills the name_en fields, and
+	// name_ruby from the upstream reading, instead of overwriting the names
+	Bilingual bool
+	// Style is the romanization style for Romaji output (empty keeps kanjikana's)
+	Style kana.Style
+}
 
-			// Translate section name
-			if err := translateString(&section.Name); err != nil {
-				return err
-			}
+// TranslateTypedTransitResponse translates the names in a TransitResponse
+// Names are overwritten with the options.Lang translation, or in bilingual
+// mode kept alongside the English name and the upstream hiragana reading
+func TranslateTypedTransitResponse(response *model.TransitResponse, options TranslateOptions) error {
+	translator := StyledTranslator{Dictionary: EnglishDictionary, Style: options.Style}
+	if localized, ok := TranslatorFor(options.Lang); ok && !options.Bilingual {
+		translator.Dictionary = localized
+	}
 
-			// Translate line name
-			if err := translateString(&section.LineName); err != nil {
-				return err
-			}
+	// Romanize every unique name concurrently first, so the walk below only
+	// reads memoized results
+	if err := prefetchRomaji(response, translator.Dictionary, runtime.GOMAXPROCS(0)); err != nil {
+		return err
+	}
 
-			// Translate transport details if present
-			if section.Transport != nil {
-				transport := section.Transport
+	return WalkNames(response, func(field NameField) error {
+		if *field.Name == "" {
+			return nil
+		}
 
-				// Translate transport name
-				if err := translateString(&transport.Name); err != nil {
-					return err
-				}
+		translated, err := translator.TranslateReading(field.ID, *field.Name, field.Reading)
+		if err != nil {
+			return err
+		}
+		if !options.Bilingual {
+			*field.Name = translated
+			return nil
+		}
 
-				// Translate company name
-				if err := translateString(&transport.Company.Name); err != nil {
-					return err
-				}
+		*field.En = translated
+		// Readings are only ever NAVITIME's; a romanized guess would invent them
+		if field.Ruby != nil && field.Reading != "" {
+			*field.Ruby = kana.KatakanaToHiragana(field.Reading)
+		}
+		return nil
+	})
+}
 
-				// Translate links
-				for k := range transport.Links {
-					link := &transport.Links[k]
-					if err := translateString(&link.Name); err != nil {
-						return err
-					}
-					if err := translateString(&link.Destination.Name); err != nil {
-						return err
-					}
-					if err := translateString(&link.From.Name); err != nil {
-						return err
-					}
-					if err := translateString(&link.To.Name); err != nil {
-						return err
-					}
-				}
+// prefetchRomaji romanizes the unique names in response with the given
+// number of workers, filling romajiMemo. Names the dictionary already covers
+// are skipped.
+func prefetchRomaji(response *model.TransitResponse, dictionary *DictionaryTranslator, workers int) error {
+	unique := make(map[string]bool)
+	_ = WalkNames(response, func(field NameField) error {
+		if *field.Name == "" {
+			return nil
+		}
+		if _, ok := dictionary.Lookup(field.ID, *field.Name); ok {
+			return nil
+		}
+		unique[*field.Name] = true
+		return nil
+	})
 
-				// Translate fare details
-				for k := range transport.FareDetail {
-					fareDetail := &transport.FareDetail[k]
-					if err := translateString(&fareDetail.Start.Name); err != nil {
-						return err
-					}
-					if err := translateString(&fareDetail.Goal.Name); err != nil {
-						return err
-					}
+	names := make(chan string)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for range min(workers, len(unique)) {
+		wg.Go(func() {
+			for name := range names {
+				if _, err := KanjiToRomaji(name); err != nil {
+					errOnce.Do(func() { firstErr = err })
 				}
 			}
-		}
+		})
+	}
+	for name := range unique {
+		names <- name
 	}
-	return nil
+	close(names)
+	wg.Wait()
+	return firstErr
 }
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"transit-api/kana"
+	"transit-api/model"
+)
+
+// loadSampleResponse reads the sample NAVITIME response at the repository root
+func loadSampleResponse(b *testing.B) model.TransitResponse {
+	b.Helper()
+	data, err := os.ReadFile("../transit.json")
+	if err != nil {
+		b.Fatalf("failed to read transit.json: %v", err)
+	}
+	var response model.TransitResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		b.Fatalf("failed to parse transit.json: %v", err)
+	}
+	return response
+}
+
+// cloneResponse deep-copies a response so each iteration translates fresh names
+func cloneResponse(b *testing.B, response model.TransitResponse) model.TransitResponse {
+	data, err := json.Marshal(response)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var clone model.TransitResponse
+	if err := json.Unmarshal(data, &clone); err != nil {
+		b.Fatal(err)
+	}
+	return clone
+}
+
+// loadFixture reads a transit response from testdata
+func loadFixture(t *testing.T, name string) model.TransitResponse {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response model.TransitResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	return response
+}
+
+// bilingualName is the name fields of one node after translation
+type bilingualName struct {
+	name, en, ruby string
+}
+
+func TestTranslateBilingual(t *testing.T) {
+	response := loadFixture(t, "bilingual.json")
+	if err := TranslateTypedTransitResponse(&response, TranslateOptions{Lang: "ko", Bilingual: true, Style: kana.Passport}); err != nil {
+		t.Fatal(err)
+	}
+
+	item := response.Items[0]
+	move := item.Sections[1]
+	link := move.Transport.Links[0]
+	fare := move.Transport.FareDetail[0]
+	tests := []struct {
+		node string
+		got  bilingualName
+		want bilingualName
+	}{
+		{"summary start", bilingualName{item.Summary.Start.Name, item.Summary.Start.NameEn, item.Summary.Start.NameRuby}, bilingualName{"新宿", "Shinjuku", "しんじゅく"}},
+		// Katakana readings are returned in hiragana
+		{"summary goal", bilingualName{item.Summary.Goal.Name, item.Summary.Goal.NameEn, item.Summary.Goal.NameRuby}, bilingualName{"東京", "Tokyo", "とうきょう"}},
+		{"section point", bilingualName{item.Sections[0].Name, item.Sections[0].NameEn, item.Sections[0].NameRuby}, bilingualName{"新宿", "Shinjuku", "しんじゅく"}},
+		// No reading upstream, so no name_ruby
+		{"section point without ruby", bilingualName{item.Sections[2].Name, item.Sections[2].NameEn, item.Sections[2].NameRuby}, bilingualName{"東京", "Tokyo", ""}},
+		{"section line", bilingualName{move.LineName, move.LineNameEn, ""}, bilingualName{"ＪＲ中央線快速", "JR Chuo Line (Rapid)", ""}},
+		{"transport", bilingualName{move.Transport.Name, move.Transport.NameEn, move.Transport.NameRuby}, bilingualName{"ＪＲ中央線快速", "JR Chuo Line (Rapid)", ""}},
+		{"company", bilingualName{move.Transport.Company.Name, move.Transport.Company.NameEn, move.Transport.Company.NameRuby}, bilingualName{"ＪＲ東日本", "JR East", ""}},
+		{"link", bilingualName{link.Name, link.NameEn, link.NameRuby}, bilingualName{"ＪＲ中央線快速", "JR Chuo Line (Rapid)", "じぇいあーるちゅうおうせんかいそく"}},
+		{"link destination", bilingualName{link.Destination.Name, link.Destination.NameEn, link.Destination.NameRuby}, bilingualName{"東京", "Tokyo", "とうきょう"}},
+		{"link from", bilingualName{link.From.Name, link.From.NameEn, link.From.NameRuby}, bilingualName{"新宿", "Shinjuku", "しんじゅく"}},
+		{"link to", bilingualName{link.To.Name, link.To.NameEn, link.To.NameRuby}, bilingualName{"東京", "Tokyo", ""}},
+		{"fare detail start", bilingualName{fare.Start.Name, fare.Start.NameEn, fare.Start.NameRuby}, bilingualName{"新宿", "Shinjuku", "しんじゅく"}},
+		{"fare detail goal", bilingualName{fare.Goal.Name, fare.Goal.NameEn, fare.Goal.NameRuby}, bilingualName{"東京", "Tokyo", ""}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %+v, want %+v", tt.node, tt.got, tt.want)
+		}
+	}
+
+	// Missing readings are left out of the JSON rather than guessed
+	encoded, err := json.Marshal(item.Sections[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(encoded), "name_ruby") {
+		t.Errorf("section without ruby encodes name_ruby: %s", encoded)
+	}
+}
+
+// Without bilingual mode the names are overwritten and no reading is added
+func TestTranslateOverwritesNames(t *testing.T) {
+	response := loadFixture(t, "bilingual.json")
+	if err := TranslateTypedTransitResponse(&response, TranslateOptions{Lang: "en"}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := response.Items[0].Sections[1].Transport.Links[0]
+	if got := response.Items[0].Summary.Start; got.Name != "Shinjuku" || got.NameEn != "" || got.NameRuby != "" {
+		t.Errorf("summary start = %+v", got)
+	}
+	if link.From.Name != "Shinjuku" || link.From.NameRuby != "" {
+		t.Errorf("link from = %+v", link.From)
+	}
+}
+
+// BenchmarkTranslateTypedTransitResponse measures translation of transit.json
+// "cold" clears the memo every iteration, as on the first request for a route;
+// "warm" keeps it, as for names already seen by the process. Bilingual output
+// romanizes every name, including those in the dictionary.
+func BenchmarkTranslateTypedTransitResponse(b *testing.B) {
+	sample := loadSampleResponse(b)
+
+	for _, tc := range []struct {
+		name    string
+		warm    bool
+		options TranslateOptions
+	}{
+		{name: "en/cold", warm: false, options: TranslateOptions{Lang: "en"}},
+		{name: "en/warm", warm: true, options: TranslateOptions{Lang: "en"}},
+		{name: "bilingual/cold", warm: false, options: TranslateOptions{Lang: "en", Bilingual: true}},
+		{name: "bilingual/warm", warm: true, options: TranslateOptions{Lang: "en", Bilingual: true}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				response := cloneResponse(b, sample)
+				if !tc.warm {
+					romajiMemo.Clear()
+				}
+				b.StartTimer()
+
+				if err := TranslateTypedTransitResponse(&response, tc.options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPrefetchRomaji compares sequential and concurrent romanization of
+// the unique names in transit.json with an empty memo
+func BenchmarkPrefetchRomaji(b *testing.B) {
+	sample := loadSampleResponse(b)
+	// An empty dictionary romanizes every name
+	everyName := NewDictionaryTranslator(nil, KanjikanaTranslator{})
+
+	for _, tc := range []struct {
+		name    string
+		workers int
+	}{
+		{name: "sequential", workers: 1},
+		{name: "parallel", workers: runtime.GOMAXPROCS(0)},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				romajiMemo.Clear()
+				b.StartTimer()
+
+				if err := prefetchRomaji(&sample, everyName, tc.workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"embed"
+	"encoding/json"
+	"strings"
+)
+
+// Translator translates a Japanese name into another script or language
+// id is the NAVITIME node ID when the name is a station, and empty otherwise
+type Translator interface {
+	Translate(id, name string) (string, error)
+}
+
+// KanjikanaTranslator transliterates names phonetically using kanjikana
+type KanjikanaTranslator struct{}
+
+// Translate converts name to capitalized Romaji, ignoring id
+func (KanjikanaTranslator) Translate(id, name string) (string, error) {
+	romaji, err := KanjiToRomaji(name)
+	if err != nil {
+		return "", err
+	}
+	return CapitalizeFirstLetter(romaji, ""), nil
+}
+
+// DictionaryEntry is a single official name in a translation dictionary
+type DictionaryEntry struct {
+	ID   string `json:"id,omitempty"`
+	Ja   string `json:"ja"`
+	Name string `json:"name"`
+}
+
+// DictionaryTranslator returns official names from a dictionary, keyed by
+// node ID first and Japanese name second, and defers to a fallback otherwise
+type DictionaryTranslator struct {
+	byID     map[string]string
+	byName   map[string]string
+	fallback Translator
+}
+
+// NewDictionaryTranslator creates a dictionary translator over entries
+func NewDictionaryTranslator(entries []DictionaryEntry, fallback Translator) *DictionaryTranslator {
+	d := &DictionaryTranslator{
+		byID:     make(map[string]string),
+		byName:   make(map[string]string),
+		fallback: fallback,
+	}
+	for _, e := range entries {
+		if e.ID != "" {
+			d.byID[e.ID] = e.Name
+		}
+		if e.Ja != "" {
+			d.byName[e.Ja] = e.Name
+		}
+	}
+	return d
+}
+
+// Lookup returns the dictionary name for id or name, without falling back
+// Names with a trailing 駅 also match their entry without it
+func (d *DictionaryTranslator) Lookup(id, name string) (string, bool) {
+	if id != "" {
+		if translated, ok := d.byID[id]; ok {
+			return translated, true
+		}
+	}
+	if translated, ok := d.byName[name]; ok {
+		return translated, true
+	}
+	if trimmed := strings.TrimSuffix(name, "駅"); trimmed != name {
+		if translated, ok := d.byName[trimmed]; ok {
+			return translated, true
+		}
+	}
+	return "", false
+}
+
+// Translate returns the dictionary name when known, otherwise the fallback's
+func (d *DictionaryTranslator) Translate(id, name string) (string, error) {
+	if translated, ok := d.Lookup(id, name); ok {
+		return translated, nil
+	}
+	return d.fallback.Translate(id, name)
+}
+
+//go:embed dictionary/*.json
+var dictionaryFS embed.FS
+
+// Dictionaries holds the bundled station, line and operator names for each
+// supported language, keyed by language tag. Names missing from a dictionary
+// fall back to Romaji from kanjikana.
+var Dictionaries = map[string]*DictionaryTranslator{
+	"en":      loadDictionary("en"),
+	"zh-Hans": loadDictionary("zh-Hans"),
+	"zh-Hant": loadDictionary("zh-Hant"),
+	"ko":      loadDictionary("ko"),
+}
+
+// EnglishDictionary holds official English station, line and operator names
+// It is also used for bilingual name_en fields
+var EnglishDictionary = Dictionaries["en"]
+
+// TranslatorFor returns the translator for a language tag
+// It reports false for Japanese and unsupported languages, which are not translated
+func TranslatorFor(lang string) (*DictionaryTranslator, bool) {
+	translator, ok := Dictionaries[lang]
+	return translator, ok
+}
+
+// loadDictionary parses the embedded dictionary for lang
+func loadDictionary(lang string) *DictionaryTranslator {
+	data, err := dictionaryFS.ReadFile("dictionary/" + lang + ".json")
+	if err != nil {
+		panic("missing embedded dictionary: " + err.Error())
+	}
+	return NewDictionaryTranslator(mustParseDictionary(data), KanjikanaTranslator{})
+}
+
+// mustParseDictionary decodes an embedded dictionary, panicking if it is malformed
+func mustParseDictionary(data []byte) []DictionaryEntry {
+	var entries []DictionaryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		panic("invalid embedded dictionary: " + err.Error())
+	}
+	return entries
+}
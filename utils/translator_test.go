@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// recordingTranslator is a fallback that records the names it was asked for
+type recordingTranslator struct {
+	calls []string
+	err   error
+}
+
+func (r *recordingTranslator) Translate(id, name string) (string, error) {
+	r.calls = append(r.calls, id+"|"+name)
+	if r.err != nil {
+		return "", r.err
+	}
+	return "fallback:" + name, nil
+}
+
+func TestDictionaryTranslator(t *testing.T) {
+	entries := []DictionaryEntry{
+		{ID: "00004212", Ja: "新橋", Name: "Shimbashi"},
+		{Ja: "新橋", Name: "Shinbashi (by name)"},
+		{Ja: "東京", Name: "Tokyo"},
+		{ID: "00009999", Name: "Only by ID"},
+	}
+
+	tests := []struct {
+		name         string
+		id           string
+		ja           string
+		want         string
+		wantFallback bool
+	}{
+		{name: "ID wins over name", id: "00004212", ja: "新橋", want: "Shimbashi"},
+		{name: "ID matches any name", id: "00004212", ja: "しんばし", want: "Shimbashi"},
+		{name: "unknown ID falls back to name", id: "00000001", ja: "新橋", want: "Shinbashi (by name)"},
+		{name: "name without ID", ja: "東京", want: "Tokyo"},
+		{name: "trailing 駅", ja: "東京駅", want: "Tokyo"},
+		{name: "entry without a name", id: "00009999", want: "Only by ID"},
+		{name: "empty name is not an entry", ja: "", want: "fallback:", wantFallback: true},
+		{name: "unknown name", ja: "両国", want: "fallback:両国", wantFallback: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := &recordingTranslator{}
+			d := NewDictionaryTranslator(entries, fallback)
+
+			got, err := d.Translate(tt.id, tt.ja)
+			if err != nil {
+				t.Fatalf("Translate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Translate(%q, %q) = %q, want %q", tt.id, tt.ja, got, tt.want)
+			}
+			if called := len(fallback.calls) > 0; called != tt.wantFallback {
+				t.Errorf("fallback called = %v, want %v", called, tt.wantFallback)
+			}
+			if _, ok := d.Lookup(tt.id, tt.ja); ok == tt.wantFallback {
+				t.Errorf("Lookup ok = %v, want %v", ok, !tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestDictionaryTranslatorFallbackError(t *testing.T) {
+	failure := errors.New("tokenizer failed")
+	d := NewDictionaryTranslator(nil, &recordingTranslator{err: failure})
+	if _, err := d.Translate("", "両国"); !errors.Is(err, failure) {
+		t.Errorf("err = %v, want the fallback's error", err)
+	}
+}
+
+func TestEmbeddedDictionaries(t *testing.T) {
+	for _, lang := range []string{"en", "zh-Hans", "zh-Hant", "ko"} {
+		t.Run(lang, func(t *testing.T) {
+			translator, ok := TranslatorFor(lang)
+			if !ok {
+				t.Fatalf("no dictionary for %s", lang)
+			}
+			if len(translator.byName) == 0 || len(translator.byID) == 0 {
+				t.Errorf("dictionary has %d names and %d IDs", len(translator.byName), len(translator.byID))
+			}
+			// Every dictionary has the node ID entries for 新橋
+			if _, ok := translator.Lookup("00004212", ""); !ok {
+				t.Error("00004212 (新橋) is missing")
+			}
+		})
+	}
+
+	if _, ok := TranslatorFor("ja"); ok {
+		t.Error("Japanese has a translator")
+	}
+	if got, _ := EnglishDictionary.Lookup("", "東京駅"); got != "Tokyo" {
+		t.Errorf("東京駅 = %q, want Tokyo", got)
+	}
+}
+
+// English names follow official signage, which marks long vowels with
+// macrons (Ōtemachi); StyleOfficialName only adapts macrons, so no other
+// accented letter may appear
+func TestEnglishDictionarySpelling(t *testing.T) {
+	for _, entry := range mustParseDictionary(readDictionary(t, "en")) {
+		if strings.ContainsFunc(entry.Name, func(r rune) bool {
+			return unicode.IsLetter(r) && r > unicode.MaxASCII && !strings.ContainsRune("ĀĒĪŌŪāēīōū", r)
+		}) {
+			t.Errorf("%s: %q has accented letters other than macrons", entry.Ja, entry.Name)
+		}
+	}
+	if got, _ := EnglishDictionary.Lookup("", "大手町"); got != "Ōtemachi" {
+		t.Errorf("大手町 = %q, want Ōtemachi", got)
+	}
+}
+
+// Every bundled dictionary decodes strictly, pairs each Japanese name with a
+// translation, and has no duplicate node IDs or Japanese names
+func TestDictionaryFiles(t *testing.T) {
+	files, err := dictionaryFS.ReadDir("dictionary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		lang := strings.TrimSuffix(file.Name(), ".json")
+		t.Run(lang, func(t *testing.T) {
+			if _, ok := Dictionaries[lang]; !ok {
+				t.Errorf("%s is not loaded into Dictionaries", file.Name())
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(readDictionary(t, lang)))
+			decoder.DisallowUnknownFields()
+			var entries []DictionaryEntry
+			if err := decoder.Decode(&entries); err != nil {
+				t.Fatalf("invalid dictionary: %v", err)
+			}
+
+			ids := make(map[string]bool)
+			names := make(map[string]bool)
+			for i, entry := range entries {
+				if strings.TrimSpace(entry.Ja) == "" || strings.TrimSpace(entry.Name) == "" {
+					t.Errorf("entry %d (%+v) needs both ja and name", i, entry)
+				}
+				if entry.ID != "" {
+					if ids[entry.ID] {
+						t.Errorf("duplicate id %s", entry.ID)
+					}
+					ids[entry.ID] = true
+				}
+				if names[entry.Ja] {
+					t.Errorf("duplicate ja %s", entry.Ja)
+				}
+				names[entry.Ja] = true
+			}
+		})
+	}
+}
+
+// readDictionary reads the embedded dictionary file for lang
+func readDictionary(t *testing.T, lang string) []byte {
+	t.Helper()
+	data, err := dictionaryFS.ReadFile("dictionary/" + lang + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
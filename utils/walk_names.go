@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"transit-api/model"
+)
+
+// NameField is a translatable name in a transit response along with the
+// fields that receive its English name and reading in bilingual mode
+type NameField struct {
+	// ID is the node ID for station names and empty for lines and operators
+	ID   string
+	Name *string
+	// Reading is the upstream ruby of the name, empty when NAVITIME sent none
+	Reading string
+	En      *string
+	// Ruby receives the reading; nil for names that never have one
+	Ruby *string
+}
+
+// WalkNames calls visit for every translatable name in a TransitResponse
+// This is the single place that knows which fields hold names
+func WalkNames(response *model.TransitResponse, visit func(NameField) error) error {
+	for i := range response.Items {
+		item := &response.Items[i]
+
+		// Summary names
+		for _, point := range []*model.Point{&item.Summary.Start, &item.Summary.Goal} {
+			if err := visit(NameField{ID: point.NodeID, Name: &point.Name, Reading: point.Ruby, En: &point.NameEn, Ruby: &point.NameRuby}); err != nil {
+				return err
+			}
+		}
+
+		// Sections
+		for j := range item.Sections {
+			section := &item.Sections[j]
+
+			if err := visit(NameField{ID: section.NodeID, Name: &section.Name, Reading: section.Ruby, En: &section.NameEn, Ruby: &section.NameRuby}); err != nil {
+				return err
+			}
+			if err := visit(NameField{Name: &section.LineName, En: &section.LineNameEn}); err != nil {
+				return err
+			}
+
+			if section.Transport == nil {
+				continue
+			}
+			transport := section.Transport
+
+			if err := visit(NameField{Name: &transport.Name, Reading: transport.Ruby, En: &transport.NameEn, Ruby: &transport.NameRuby}); err != nil {
+				return err
+			}
+			company := &transport.Company
+			if err := visit(NameField{Name: &company.Name, Reading: company.Ruby, En: &company.NameEn, Ruby: &company.NameRuby}); err != nil {
+				return err
+			}
+
+			// Links
+			for k := range transport.Links {
+				link := &transport.Links[k]
+				if err := visit(NameField{Name: &link.Name, Reading: link.Ruby, En: &link.NameEn, Ruby: &link.NameRuby}); err != nil {
+					return err
+				}
+				destination := &link.Destination
+				if err := visit(NameField{ID: destination.ID, Name: &destination.Name, Reading: destination.Ruby, En: &destination.NameEn, Ruby: &destination.NameRuby}); err != nil {
+					return err
+				}
+				if err := visitStation(&link.From, visit); err != nil {
+					return err
+				}
+				if err := visitStation(&link.To, visit); err != nil {
+					return err
+				}
+			}
+
+			// Fare details
+			for k := range transport.FareDetail {
+				fareDetail := &transport.FareDetail[k]
+				if err := visitStation(&fareDetail.Start, visit); err != nil {
+					return err
+				}
+				if err := visitStation(&fareDetail.Goal, visit); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// visitStation visits the name of a link or fare detail station
+func visitStation(station *model.Station, visit func(NameField) error) error {
+	return visit(NameField{ID: station.ID, Name: &station.Name, Reading: station.Ruby, En: &station.NameEn, Ruby: &station.NameRuby})
+}